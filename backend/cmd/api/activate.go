@@ -1,12 +1,12 @@
 package main
 
 import (
-	"context"
 	"crypto/sha256"
 	"errors"
 	"fmt"
 	"net/http"
 
+	"crossfitbox.booking.system/internal/data"
 	"crossfitbox.booking.system/internal/tokens"
 	"crossfitbox.booking.system/internal/validator"
 )
@@ -34,7 +34,7 @@ func (app *application) activateUserHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	hash, err := app.getFromRedis(fmt.Sprintf("activation_%s", id))
+	hash, err := app.getFromRedis(r.Context(), fmt.Sprintf("activation_%s", id))
 	if err != nil {
 		app.badRequestResponse(w, r, err)
 		return
@@ -50,14 +50,20 @@ func (app *application) activateUserHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	err = app.models.User.Activate(*id)
+	// The token is only deleted once the account is activated, so that it can be
+	// used again when the request was sent to the wrong box.
+	err = app.models.User.Activate(r.Context(), app.contextGetTenant(r).ID, *id)
 	if err != nil {
-		app.serveErrorResponse(w, r, err)
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serveErrorResponse(w, r, err)
+		}
 		return
 	}
 
-	ctx := context.Background()
-	deleted, err := app.redisClient.Del(ctx, fmt.Sprintf("activation_%s", id)).Result()
+	deleted, err := app.redisClient.Del(r.Context(), fmt.Sprintf("activation_%s", id)).Result()
 	if err != nil {
 		app.logger.PrintError(err, map[string]string{
 			"key": fmt.Sprintf("activation_%s", id),
@@ -66,5 +72,5 @@ func (app *application) activateUserHandler(w http.ResponseWriter, r *http.Reque
 
 	app.logger.PrintInfo(fmt.Sprintf("Token hash was deleted successfully :activation_%d", deleted), nil)
 
-	app.writeJSON(w, http.StatusOK, "Account activated successfully.", nil)
+	app.writeJSON(w, http.StatusOK, app.translate(r, "Account activated successfully."), nil)
 }
@@ -9,14 +9,16 @@ import (
 	"strings"
 	"time"
 
-	"github.com/joho/godotenv"
+	"crossfitbox.booking.system/internal/env"
+	"crossfitbox.booking.system/internal/jsonlog"
+	"crossfitbox.booking.system/internal/tracing"
 	"github.com/rs/cors"
 )
 
 func updateConfigWithVariables() (*config, error) {
 	var cfg config
 
-	err := godotenv.Load(".env")
+	err := env.Load()
 	if err != nil {
 		log.Fatal("Error loading .env file")
 	}
@@ -29,25 +31,14 @@ func updateConfigWithVariables() (*config, error) {
 
 	flag.IntVar(&cfg.port, "port", port, "API server port")
 	flag.StringVar(&cfg.env, "env", "development", "Environment (development|staging|production)")
+	flag.BoolVar(&cfg.migrate, "migrate", false, "Apply pending database migrations before starting the server")
 
-	// DATABASE Config
-	maxOpenConnsStr := os.Getenv("DB_MAX_OPEN_CONNS")
-	maxOpenConns, err := strconv.Atoi(maxOpenConnsStr)
-	if err != nil {
-		log.Fatal(err)
-	}
-	maxIdleConnsStr := os.Getenv("DB_MAX_IDLE_CONNS")
-	maxIdleConns, err := strconv.Atoi(maxIdleConnsStr)
+	// DB Config
+	err = cfg.db.RegisterFlags(flag.CommandLine)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	// DB Config
-	flag.StringVar(&cfg.db.dsn, "db-dsn", os.Getenv("CFBOX_DB_DSN"), "PostgreSQL DSN")
-	flag.IntVar(&cfg.db.maxOpenConns, "db-max-open-conns", maxOpenConns, "PostgreSQL max open connections")
-	flag.IntVar(&cfg.db.maxIdleConns, "db-max-idle-conns", maxIdleConns, "PostgreSQL max idle connections")
-	flag.StringVar(&cfg.db.maxIdleTime, "db-max-idle-time", os.Getenv("DB_MAX_IDLE_TIME"), "PostgreSQL max connection idle time")
-
 	// Email Config
 	emailPortStr := os.Getenv("EMAIL_SERVER_PORT")
 	emailPort, err := strconv.Atoi(emailPortStr)
@@ -60,9 +51,56 @@ func updateConfigWithVariables() (*config, error) {
 	flag.StringVar(&cfg.smtp.password, "smtp-password", os.Getenv("EMAIL_PASSWORD"), "SMTP password")
 	flag.StringVar(&cfg.smtp.sender, "smtp-sender", os.Getenv("EMAIL_SENDER"), "SMTP sender")
 
+	// Mail transport
+	mailTransport := os.Getenv("MAIL_TRANSPORT")
+	if mailTransport == "" {
+		mailTransport = "smtp"
+	}
+	flag.StringVar(&cfg.mail.transport, "mail-transport", mailTransport, "Mail transport (smtp|ses|file)")
+	flag.StringVar(&cfg.mail.dir, "mail-dir", os.Getenv("MAIL_DIR"), "Directory for .eml files written by the file transport (stdout if empty)")
+	flag.StringVar(&cfg.mail.sesRegion, "ses-region", os.Getenv("AWS_REGION"), "AWS region of the SES transport")
+	flag.StringVar(&cfg.mail.sesProfile, "ses-profile", os.Getenv("AWS_PROFILE"), "AWS shared config profile of the SES transport")
+
 	// Redis config
 	flag.StringVar(&cfg.redisURL, "redis-url", os.Getenv("REDIS_URL"), "Redis URL")
 
+	// Metrics. PUT /log-level is unauthenticated, so the internal server only
+	// listens on loopback unless an address is configured.
+	metricsAddr, ok := os.LookupEnv("METRICS_ADDR")
+	if !ok {
+		metricsAddr = "127.0.0.1:9090"
+	}
+	flag.StringVar(&cfg.metricsAddr, "metrics-addr", metricsAddr, "Address of the unauthenticated internal server for /metrics and /log-level, kept apart from the public API port (disabled if empty)")
+
+	// Logging
+	logLevel := jsonlog.LevelInfo
+	if s := os.Getenv("LOG_LEVEL"); s != "" {
+		logLevel, err = jsonlog.ParseLevel(s)
+		if err != nil {
+			return nil, err
+		}
+	}
+	flag.TextVar(&cfg.log.level, "log-level", logLevel, "Minimum log level (debug|info|warn|error|fatal|off)")
+	flag.BoolVar(&cfg.log.stackTraces, "log-stack-traces", os.Getenv("LOG_STACK_TRACES") == "true", "Include stack traces in error log entries")
+
+	// Tracing
+	traceExporter := os.Getenv("TRACE_EXPORTER")
+	if traceExporter == "" {
+		traceExporter = tracing.ExporterNone
+	}
+	flag.StringVar(&cfg.tracing.exporter, "trace-exporter", traceExporter, "Trace exporter (none|otlp|stdout); otlp is configured with the OTEL_EXPORTER_OTLP_* variables")
+
+	// Readiness
+	shutdownDelay := time.Duration(0)
+	if s := os.Getenv("SHUTDOWN_DELAY"); s != "" {
+		shutdownDelay, err = time.ParseDuration(s)
+		if err != nil {
+			return nil, err
+		}
+	}
+	flag.BoolVar(&cfg.readiness.smtp, "readyz-smtp", os.Getenv("READYZ_SMTP") == "true", "Check the SMTP server in the readiness probe (smtp transport only)")
+	flag.DurationVar(&cfg.readiness.shutdownDelay, "shutdown-delay", shutdownDelay, "Time /readyz reports the shutdown before the server stops accepting connections")
+
 	// Token Expiration
 	tokenExpirationStr := os.Getenv("TOKEN_EXPIRATION")
 	duration, err := time.ParseDuration(tokenExpirationStr)
@@ -72,18 +110,35 @@ func updateConfigWithVariables() (*config, error) {
 	cfg.tokenExpiration.durationString = tokenExpirationStr
 	cfg.tokenExpiration.duration = duration
 
+	// Background jobs
+	flag.IntVar(&cfg.jobs.workers, "jobs-workers", 2, "Number of background job workers")
+	flag.DurationVar(&cfg.jobs.pollInterval, "jobs-poll-interval", time.Second, "Interval between polls for due background jobs")
+	flag.DurationVar(&cfg.jobs.retention, "jobs-retention", 7*24*time.Hour, "How long completed jobs, dispatched outbox events and webhook delivery logs are kept (0 keeps them forever)")
+
 	// Frontend URL
 	flag.StringVar(&cfg.frontendURL, "frontend-url", os.Getenv("FRONTEND_URL"), "Frontend URL")
 
+	signupPath := os.Getenv("FRONTEND_SIGNUP_PATH")
+	if signupPath == "" {
+		signupPath = "/auth/signup"
+	}
+	flag.StringVar(&cfg.signupPath, "frontend-signup-path", signupPath, "Path of the frontend sign up page referral links point to")
+
 	// CORS
 	flag.Func("cors-allowed-origins", "Allowed CORS origins (space separated)", func(s string) error {
 		cfg.cors = cors.Options{
 			AllowedOrigins:   strings.Fields(s),
 			AllowCredentials: true,
+			AllowedHeaders:   []string{"Origin", "Accept", "Content-Type", "X-Requested-With", "X-Tenant", "X-Request-ID", "traceparent", "tracestate"},
+			ExposedHeaders:   []string{"X-Request-ID"},
 		}
 		return nil
 	})
 
+	// Tenants
+	flag.StringVar(&cfg.tenant.baseDomain, "tenant-base-domain", os.Getenv("TENANT_BASE_DOMAIN"), "Base domain whose subdomains identify tenants")
+	flag.StringVar(&cfg.tenant.defaultSlug, "tenant-default", env.DefaultTenant(), "Tenant used when none is resolved from the request (empty to disable)")
+
 	// Secret
 	flag.StringVar(&cfg.secret.HMC, "secret-key", os.Getenv("HMC_SECRET_KEY"), "HMC Secret Key")
 	secretKey, err := hex.DecodeString(cfg.secret.HMC)
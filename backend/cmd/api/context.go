@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"crossfitbox.booking.system/internal/data"
+	"crossfitbox.booking.system/internal/jsonlog"
+)
+
+type contextKey string
+
+const (
+	tenantContextKey  = contextKey("tenant")
+	userContextKey    = contextKey("user")
+	requestContextKey = contextKey("request")
+)
+
+// requestInfo is shared by the logRequests() middleware with the handlers it
+// wraps. It is a pointer so that the user set by requireAuthenticatedUser() deep
+// in the chain shows up in the access log written by logRequests().
+type requestInfo struct {
+	id     string
+	userID string
+}
+
+// The contextSetRequestInfo() method returns a new copy of the request with the
+// provided requestInfo added to the context.
+func (app *application) contextSetRequestInfo(r *http.Request, info *requestInfo) *http.Request {
+	ctx := context.WithValue(r.Context(), requestContextKey, info)
+	return r.WithContext(ctx)
+}
+
+// The contextGetRequestID() returns the ID of the request, or an empty string for
+// requests that didn't go through logRequests().
+func (app *application) contextGetRequestID(r *http.Request) string {
+	info, ok := r.Context().Value(requestContextKey).(*requestInfo)
+	if !ok {
+		return ""
+	}
+
+	return info.id
+}
+
+// The contextSetLogger() method returns a new copy of the request carrying the
+// provided logger, which requestLogger() returns to the handlers down the chain.
+func (app *application) contextSetLogger(r *http.Request, logger *jsonlog.Logger) *http.Request {
+	ctx := jsonlog.NewContext(r.Context(), logger)
+	return r.WithContext(ctx)
+}
+
+// The requestLogger() method returns the logger bound to the request, with the
+// request ID and, once authenticated, the user ID attached to every entry. It falls
+// back to the application logger for requests that didn't go through logRequests().
+func (app *application) requestLogger(r *http.Request) *jsonlog.Logger {
+	return jsonlog.FromContext(r.Context(), app.logger)
+}
+
+// The contextSetTenant() method returns a new copy of the request with the provided
+// Tenant struct added to the context.
+func (app *application) contextSetTenant(r *http.Request, tenant *data.Tenant) *http.Request {
+	ctx := context.WithValue(r.Context(), tenantContextKey, tenant)
+	return r.WithContext(ctx)
+}
+
+// The contextGetTenant() retrieves the Tenant struct from the request context. It is
+// only called from handlers wrapped by requireTenant(), so a missing value is a bug
+// and we panic.
+func (app *application) contextGetTenant(r *http.Request) *data.Tenant {
+	tenant, ok := r.Context().Value(tenantContextKey).(*data.Tenant)
+	if !ok {
+		panic("missing tenant value in request context")
+	}
+
+	return tenant
+}
+
+// The contextSetUser() method returns a new copy of the request with the provided
+// User struct added to the context.
+func (app *application) contextSetUser(r *http.Request, user *data.User) *http.Request {
+	if info, ok := r.Context().Value(requestContextKey).(*requestInfo); ok {
+		info.userID = user.ID.String()
+	}
+
+	r = app.contextSetLogger(r, app.requestLogger(r).With("user_id", user.ID.String()))
+
+	ctx := context.WithValue(r.Context(), userContextKey, user)
+	return r.WithContext(ctx)
+}
+
+// The contextGetUser() retrieves the User struct from the request context. It is
+// only called from handlers wrapped by requireAuthenticatedUser().
+func (app *application) contextGetUser(r *http.Request) *data.User {
+	user, ok := r.Context().Value(userContextKey).(*data.User)
+	if !ok {
+		panic("missing user value in request context")
+	}
+
+	return user
+}
@@ -7,15 +7,34 @@ import (
 
 // The logError() method is a generic helper function for logging an error message.
 func (app *application) logError(r *http.Request, err error) {
-	app.logger.PrintError(err, map[string]string{
-		"request_method": r.Method,
-		"request_url":    r.URL.String(),
-	})
+	app.requestLogger(r).Error(err,
+		"request_method", r.Method,
+		"request_url", r.URL.String(),
+	)
 }
 
 // The errorResponse() method is a generic helper for sending JSON-formatted error
 // messages to the client with a given status code.
+// String messages and the values of validation error maps are translated to the
+// language of the request.
 func (app *application) errorResponse(w http.ResponseWriter, r *http.Request, status int, message interface{}) {
+	switch m := message.(type) {
+	case string:
+		message = app.translate(r, m)
+	case map[string]string:
+		translated := make(map[string]string, len(m))
+		for key, value := range m {
+			translated[key] = app.translate(r, value)
+		}
+		message = translated
+	}
+
+	app.writeError(w, r, status, message)
+}
+
+// The writeError() method sends message as is, for messages that are already
+// translated.
+func (app *application) writeError(w http.ResponseWriter, r *http.Request, status int, message interface{}) {
 	env := envelope{"error": message}
 
 	err := app.writeJSON(w, status, env, nil)
@@ -42,11 +61,18 @@ func (app *application) notFoundResponse(w http.ResponseWriter, r *http.Request)
 	app.errorResponse(w, r, http.StatusNotFound, message)
 }
 
+// The tenantNotFoundResponse() method will be used when the request can't be matched
+// to an active box.
+func (app *application) tenantNotFoundResponse(w http.ResponseWriter, r *http.Request) {
+	message := "the requested box could not be found"
+	app.errorResponse(w, r, http.StatusNotFound, message)
+}
+
 // The methodNotAllowedResponse() method will be used to send a 405 Method Not Allowed
 // status code and JSON response to the client.
 func (app *application) MethodNotAllowed(w http.ResponseWriter, r *http.Request) {
-	message := fmt.Sprintf("the %s method is not supported for this resource", r.Method)
-	app.errorResponse(w, r, http.StatusMethodNotAllowed, message)
+	message := fmt.Sprintf(app.translate(r, "the %s method is not supported for this resource"), r.Method)
+	app.writeError(w, r, http.StatusMethodNotAllowed, message)
 }
 
 // The method badRequestResponse() method will send a 400 status code and JSON response to the client
@@ -68,3 +94,8 @@ func (app *application) unauthorizedResponse(w http.ResponseWriter, r *http.Requ
 	app.logError(r, err)
 	app.errorResponse(w, r, http.StatusUnauthorized, err.Error())
 }
+
+func (app *application) notPermittedResponse(w http.ResponseWriter, r *http.Request) {
+	message := "your user account doesn't have the necessary permissions to access this resource"
+	app.errorResponse(w, r, http.StatusForbidden, message)
+}
@@ -1,9 +1,34 @@
 package main
 
 import (
+	"context"
 	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
+// readinessTimeout bounds each dependency check of the readiness probe.
+const readinessTimeout = 2 * time.Second
+
+// dependency is a service the API can't serve requests without, checked by the
+// readiness probe.
+type dependency struct {
+	name  string
+	check func(ctx context.Context) error
+}
+
+// health holds the state reported by the liveness and readiness probes.
+type health struct {
+	dependencies []dependency
+	shuttingDown atomic.Bool
+}
+
+type dependencyStatus struct {
+	Status    string  `json:"status"`
+	LatencyMS float64 `json:"latency_ms"`
+}
+
 func (app *application) healthcheckHandler(w http.ResponseWriter, r *http.Request) {
 	data := envelope{
 		"status": "available",
@@ -18,3 +43,80 @@ func (app *application) healthcheckHandler(w http.ResponseWriter, r *http.Reques
 		app.serveErrorResponse(w, r, err)
 	}
 }
+
+// The livenessHandler() reports that the process is up and serving requests. It
+// doesn't check any dependency, so that an outage of the database doesn't get
+// every instance restarted.
+func (app *application) livenessHandler(w http.ResponseWriter, r *http.Request) {
+	err := app.writeJSON(w, http.StatusOK, envelope{"status": "alive"}, nil)
+	if err != nil {
+		app.serveErrorResponse(w, r, err)
+	}
+}
+
+// The readinessHandler() checks every dependency concurrently and reports the status
+// and latency of each. It responds 503 Service Unavailable when a dependency is
+// down or once the server has begun shutting down, so that no new traffic is
+// routed to the instance.
+func (app *application) readinessHandler(w http.ResponseWriter, r *http.Request) {
+	if app.health.shuttingDown.Load() {
+		err := app.writeJSON(w, http.StatusServiceUnavailable, envelope{"status": "shutting_down"}, nil)
+		if err != nil {
+			app.serveErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	statuses := make(map[string]dependencyStatus, len(app.health.dependencies))
+	ready := true
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	for _, dep := range app.health.dependencies {
+		wg.Add(1)
+
+		go func(dep dependency) {
+			defer wg.Done()
+
+			ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+			defer cancel()
+
+			start := time.Now()
+			err := dep.check(ctx)
+
+			status := dependencyStatus{
+				Status:    "up",
+				LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+			}
+
+			// The error is logged rather than returned, as the probe is public.
+			if err != nil {
+				status.Status = "down"
+				app.requestLogger(r).Error(err, "dependency", dep.name)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			statuses[dep.name] = status
+			if err != nil {
+				ready = false
+			}
+		}(dep)
+	}
+
+	wg.Wait()
+
+	data := envelope{"status": "ready", "dependencies": statuses}
+	code := http.StatusOK
+	if !ready {
+		data["status"] = "unavailable"
+		code = http.StatusServiceUnavailable
+	}
+
+	err := app.writeJSON(w, code, data, nil)
+	if err != nil {
+		app.serveErrorResponse(w, r, err)
+	}
+}
@@ -15,6 +15,7 @@ import (
 
 	"crossfitbox.booking.system/internal/cookies"
 	"crossfitbox.booking.system/internal/data"
+	"crossfitbox.booking.system/internal/i18n"
 	"crossfitbox.booking.system/internal/validator"
 	"github.com/google/uuid"
 	"github.com/julienschmidt/httprouter"
@@ -149,8 +150,40 @@ func (app *application) readInt(qs url.Values, key string, defaultValue int, v *
 	return i
 }
 
-func (app *application) storeInRedis(prefix string, hash string, userID uuid.UUID, expiration time.Duration) error {
-	ctx := context.Background()
+func (app *application) readBool(qs url.Values, key string, defaultValue bool, v *validator.Validator) bool {
+	s := qs.Get(key)
+
+	if s == "" {
+		return defaultValue
+	}
+
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		v.AddError(key, "must be a boolean value")
+		return defaultValue
+	}
+
+	return b
+}
+
+// The locale() helper returns the language to answer the request in: the locale
+// of the logged in user when there is one, otherwise the best match for the
+// Accept-Language header.
+func (app *application) locale(r *http.Request) string {
+	user, ok := r.Context().Value(userContextKey).(*data.User)
+	if ok && i18n.Supported(user.Profile.Locale) {
+		return user.Profile.Locale
+	}
+
+	return i18n.Match(r.Header.Get("Accept-Language"))
+}
+
+// The translate() helper returns message in the language of the request.
+func (app *application) translate(r *http.Request, message string) string {
+	return i18n.Translate(app.locale(r), message)
+}
+
+func (app *application) storeInRedis(ctx context.Context, prefix string, hash string, userID uuid.UUID, expiration time.Duration) error {
 	err := app.redisClient.Set(
 		ctx,
 		fmt.Sprintf("%s%s", prefix, userID),
@@ -164,9 +197,7 @@ func (app *application) storeInRedis(prefix string, hash string, userID uuid.UUI
 	return nil
 }
 
-func (app *application) getFromRedis(key string) (*string, error) {
-	ctx := context.Background()
-
+func (app *application) getFromRedis(ctx context.Context, key string) (*string, error) {
 	hash, err := app.redisClient.Get(ctx, key).Result()
 	if err != nil {
 		return nil, err
@@ -175,7 +206,11 @@ func (app *application) getFromRedis(key string) (*string, error) {
 	return &hash, nil
 }
 
-func (app *application) background(fn func()) {
+// The background() helper runs fn in a goroutine the server waits for on shutdown.
+// A panic is logged with the ID of the request r that started the goroutine.
+func (app *application) background(r *http.Request, fn func()) {
+	logger := app.requestLogger(r)
+
 	app.wg.Add(1)
 
 	go func() {
@@ -184,7 +219,7 @@ func (app *application) background(fn func()) {
 		// Recover any panic.
 		defer func() {
 			if err := recover(); err != nil {
-				app.logger.PrintError(fmt.Errorf("%s", err), nil)
+				logger.Error(fmt.Errorf("%s", err))
 			}
 		}()
 		// Execute the arbitrary function that we passed as the parameter.
@@ -192,6 +227,9 @@ func (app *application) background(fn func()) {
 	}()
 }
 
+// The extractParamsFromSession() helper decodes the session cookie. A session is
+// only valid for the box it was opened in, so a session of another tenant is
+// rejected as if there were none.
 func (app *application) extractParamsFromSession(r *http.Request) (*data.UserID, *int, error) {
 	gobEncodedValue, err := cookies.ReadEncrypted(r, "sessionid", app.config.secret.secretKey)
 	if err != nil {
@@ -220,5 +258,10 @@ func (app *application) extractParamsFromSession(r *http.Request) (*data.UserID,
 		return nil, &status, errors.New("something happened getting your cookie data")
 	}
 
+	if userID.TenantID != app.contextGetTenant(r).ID {
+		status := http.StatusUnauthorized
+		return nil, &status, errors.New("you are not authorized to access this resource")
+	}
+
 	return &userID, nil, nil
 }
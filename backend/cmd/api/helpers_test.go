@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"crossfitbox.booking.system/internal/cookies"
+	"crossfitbox.booking.system/internal/data"
+	"crossfitbox.booking.system/internal/jsonlog"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/extra/redisotel/v9"
+	"github.com/redis/go-redis/v9"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestExtractParamsFromSession(t *testing.T) {
+	app := &application{}
+	app.config.secret.secretKey = bytes.Repeat([]byte{1}, 32)
+
+	box := &data.Tenant{ID: uuid.New()}
+	session := data.UserID{Id: uuid.New(), TenantID: box.ID}
+	cookie := sessionCookie(t, app, session)
+
+	tests := []struct {
+		name   string
+		tenant *data.Tenant
+		status int
+	}{
+		{"same box", box, 0},
+		{"other box", &data.Tenant{ID: uuid.New()}, http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/api/v1/users/current-user", nil)
+			r.AddCookie(cookie)
+			r = app.contextSetTenant(r, tt.tenant)
+
+			userID, status, err := app.extractParamsFromSession(r)
+			if tt.status == 0 {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if *userID != session {
+					t.Errorf("session = %+v, want %+v", *userID, session)
+				}
+				return
+			}
+
+			if err == nil || *status != tt.status {
+				t.Errorf("status = %v, err = %v, want status %d", status, err, tt.status)
+			}
+		})
+	}
+}
+
+// sessionCookie returns the sessionid cookie the login handler sets for session.
+func sessionCookie(t *testing.T, app *application, session data.UserID) *http.Cookie {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(&session); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := httptest.NewRecorder()
+	err := cookies.WriteEncrypted(rec, http.Cookie{Name: "sessionid", Value: buf.String()}, app.config.secret.secretKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return rec.Result().Cookies()[0]
+}
+
+// TestRedisSpansJoinRequestTrace checks that the session lookup is traced as a
+// child of the request, rather than as a trace of its own.
+func TestRedisSpansJoinRequestTrace(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	// No server is needed: the command span is recorded when the dial fails.
+	redisClient := redis.NewClient(&redis.Options{
+		Dialer: func(context.Context, string, string) (net.Conn, error) {
+			return nil, errors.New("no redis server")
+		},
+		MaxRetries: -1,
+	})
+	defer redisClient.Close()
+
+	err := redisotel.InstrumentTracing(redisClient, redisotel.WithTracerProvider(provider))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app := &application{
+		logger:      jsonlog.New(io.Discard, jsonlog.LevelOff),
+		redisClient: redisClient,
+	}
+	app.config.secret.secretKey = bytes.Repeat([]byte{1}, 32)
+
+	box := &data.Tenant{ID: uuid.New()}
+
+	ctx, span := provider.Tracer("test").Start(context.Background(), "request")
+	r := httptest.NewRequest(http.MethodGet, "/api/v1/users/current-user", nil).WithContext(ctx)
+	r.AddCookie(sessionCookie(t, app, data.UserID{Id: uuid.New(), TenantID: box.ID}))
+	r = app.contextSetTenant(r, box)
+
+	rec := httptest.NewRecorder()
+	app.currentUserHandler(rec, r)
+	span.End()
+
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+
+	var found bool
+	for _, s := range recorder.Ended() {
+		if s.Name() != "get" {
+			continue
+		}
+		found = true
+
+		if s.SpanContext().TraceID() != span.SpanContext().TraceID() {
+			t.Errorf("redis span trace ID = %s, want %s", s.SpanContext().TraceID(), span.SpanContext().TraceID())
+		}
+		if s.Parent().SpanID() != span.SpanContext().SpanID() {
+			t.Errorf("redis span parent = %s, want the request span %s", s.Parent().SpanID(), span.SpanContext().SpanID())
+		}
+	}
+	if !found {
+		t.Fatal("no span recorded for the redis GET")
+	}
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"crossfitbox.booking.system/internal/data"
+	"crossfitbox.booking.system/internal/tokens"
+	"crossfitbox.booking.system/internal/validator"
+)
+
+// Job kinds handled by the worker pool.
+const (
+	jobWelcomeEmail    = "user_welcome_email"
+	jobWebhookFanout   = "webhook_fanout"
+	jobWebhookDelivery = "webhook_delivery"
+)
+
+func (app *application) registerJobHandlers() {
+	app.workers.Register(jobWelcomeEmail, app.sendWelcomeEmail)
+	app.workers.Register(jobWebhookFanout, app.fanOutWebhooks)
+	app.workers.Register(jobWebhookDelivery, app.deliverWebhookJob)
+
+	app.workers.Subscribe(data.EventUserRegistered, jobWelcomeEmail)
+
+	for _, eventType := range data.WebhookEventTypes {
+		app.workers.Subscribe(eventType, jobWebhookFanout)
+	}
+}
+
+// The sendWelcomeEmail() job runs for every data.EventUserRegistered event. The
+// activation token is derived from the event, so a retried job sends the same
+// token without the secret being persisted, and once the email is sent it is
+// recorded, so a redelivered event doesn't send it again.
+func (app *application) sendWelcomeEmail(ctx context.Context, job *data.Job) error {
+	var event data.Event
+
+	err := json.Unmarshal(job.Payload, &event)
+	if err != nil {
+		return err
+	}
+
+	var payload data.UserRegisteredEvent
+
+	err = json.Unmarshal(event.Data, &payload)
+	if err != nil {
+		return err
+	}
+
+	// Users created already active, e.g. by cfboxctl, have nothing to activate.
+	_, err = app.models.User.Get(ctx, job.TenantID, payload.UserID)
+	if err == nil {
+		return nil
+	} else if !errors.Is(err, data.ErrRecordNotFound) {
+		return err
+	}
+
+	sentKey := fmt.Sprintf("welcome_email_%s", event.ID)
+
+	sent, err := app.redisClient.Exists(ctx, sentKey).Result()
+	if err != nil {
+		return err
+	}
+	if sent > 0 {
+		return nil
+	}
+
+	otp := tokens.DeriveOTP(app.config.secret.secretKey, fmt.Sprintf("activation/%s", event.ID))
+
+	err = app.storeInRedis(ctx, "activation_", otp.Hash, payload.UserID, app.config.tokenExpiration.duration)
+	if err != nil {
+		return err
+	}
+
+	expiration := time.Now().Add(app.config.tokenExpiration.duration)
+
+	mailData := map[string]interface{}{
+		"token":       tokens.FormatOTP(otp.Secret),
+		"firstName":   payload.FirstName,
+		"userID":      payload.UserID,
+		"frontendURL": app.config.frontendURL,
+		"expiration":  app.config.tokenExpiration.durationString,
+		"exact":       expiration.Format(time.RFC1123),
+	}
+
+	err = app.mailer.Send(ctx, payload.Email, payload.Locale, "user_welcome.tmpl", mailData)
+	if err != nil {
+		return err
+	}
+
+	// The token can't be used once it expired, so neither can a record kept longer.
+	return app.redisClient.Set(ctx, sentKey, job.ID.String(), app.config.tokenExpiration.duration).Err()
+}
+
+func (app *application) listJobsHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Kind   string
+		Status string
+		data.Filters
+	}
+
+	v := validator.New()
+
+	qs := r.URL.Query()
+
+	input.Kind = app.readString(qs, "kind", "")
+	input.Status = app.readString(qs, "status", "")
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Filters.Sort = app.readString(qs, "sort", "-created_at")
+
+	input.Filters.SortSafelist = []string{"created_at", "run_at", "attempts", "-created_at", "-run_at", "-attempts"}
+
+	if input.Status != "" {
+		v.Check(validator.In(input.Status, data.JobPending, data.JobRunning, data.JobCompleted, data.JobDead), "status", "invalid status value")
+	}
+
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationErrors(w, r, v.Errors)
+		return
+	}
+
+	jobs, metadata, err := app.models.Jobs.GetAll(r.Context(), app.contextGetTenant(r).ID, input.Kind, input.Status, input.Filters)
+	if err != nil {
+		app.serveErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"jobs": jobs, "metadata": metadata}, nil)
+	if err != nil {
+		app.serveErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) showJobHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	job, err := app.models.Jobs.Get(r.Context(), app.contextGetTenant(r).ID, *id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serveErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"job": job}, nil)
+	if err != nil {
+		app.serveErrorResponse(w, r, err)
+	}
+}
+
+// The replayJobHandler() puts a dead job back in the queue.
+func (app *application) replayJobHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	job, err := app.models.Jobs.Replay(r.Context(), app.contextGetTenant(r).ID, *id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serveErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusAccepted, envelope{"job": job}, nil)
+	if err != nil {
+		app.serveErrorResponse(w, r, err)
+	}
+}
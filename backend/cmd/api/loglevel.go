@@ -0,0 +1,58 @@
+package main
+
+import (
+	"net/http"
+
+	"crossfitbox.booking.system/internal/jsonlog"
+	"crossfitbox.booking.system/internal/validator"
+)
+
+// The showLogLevelHandler() returns the minimum level of the entries logged by the
+// server.
+func (app *application) showLogLevelHandler(w http.ResponseWriter, r *http.Request) {
+	err := app.writeJSON(w, http.StatusOK, envelope{"level": app.logger.Level()}, nil)
+	if err != nil {
+		app.serveErrorResponse(w, r, err)
+	}
+}
+
+// The updateLogLevelHandler() changes the minimum log level at runtime, e.g. to turn
+// on debug logging while investigating an issue. The level applies to the whole
+// process and lasts until the next change or restart, which is why it is only
+// served on the internal port.
+func (app *application) updateLogLevelHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Level *string `json:"level"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	var level jsonlog.Level
+	if input.Level == nil {
+		v.AddError("level", "must be provided")
+	} else {
+		level, err = jsonlog.ParseLevel(*input.Level)
+		v.Check(err == nil, "level", "must be one of debug, info, warn, error, fatal or off")
+	}
+
+	if !v.Valid() {
+		app.failedValidationErrors(w, r, v.Errors)
+		return
+	}
+
+	previous := app.logger.Level()
+	app.logger.SetLevel(level)
+
+	app.requestLogger(r).Warn("log level changed", "from", previous, "to", level)
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"level": level}, nil)
+	if err != nil {
+		app.serveErrorResponse(w, r, err)
+	}
+}
@@ -2,14 +2,20 @@ package main
 
 import (
 	"context"
-	"database/sql"
+	"flag"
+	"fmt"
+	"log/slog"
 	"os"
 	"sync"
 	"time"
 
 	"crossfitbox.booking.system/internal/data"
+	"crossfitbox.booking.system/internal/env"
 	"crossfitbox.booking.system/internal/jsonlog"
 	"crossfitbox.booking.system/internal/mailer"
+	"crossfitbox.booking.system/internal/tracing"
+	"crossfitbox.booking.system/internal/webhooks"
+	"crossfitbox.booking.system/internal/worker"
 	_ "github.com/lib/pq"
 	"github.com/redis/go-redis/v9"
 	"github.com/rs/cors"
@@ -18,22 +24,25 @@ import (
 const version = "1.0.0"
 
 type config struct {
-	port int
-	env  string
-	db   struct {
-		dsn          string
-		maxOpenConns int
-		maxIdleConns int
-		maxIdleTime  string
-	}
-	smtp struct {
+	port    int
+	env     string
+	migrate bool
+	db      env.DB
+	smtp    struct {
 		host     string
 		port     int
 		username string
 		password string
 		sender   string
 	}
+	mail struct {
+		transport  string
+		dir        string
+		sesRegion  string
+		sesProfile string
+	}
 	redisURL        string
+	metricsAddr     string
 	tokenExpiration struct {
 		durationString string
 		duration       time.Duration
@@ -43,8 +52,29 @@ type config struct {
 		secretKey         []byte
 		sessionExpiration time.Duration
 	}
+	jobs struct {
+		workers      int
+		pollInterval time.Duration
+		retention    time.Duration
+	}
+	log struct {
+		level       jsonlog.Level
+		stackTraces bool
+	}
+	tracing struct {
+		exporter string
+	}
+	readiness struct {
+		smtp          bool
+		shutdownDelay time.Duration
+	}
 	frontendURL string
+	signupPath  string
 	cors        cors.Options
+	tenant      struct {
+		baseDomain  string
+		defaultSlug string
+	}
 }
 
 type application struct {
@@ -53,6 +83,11 @@ type application struct {
 	models      data.Models
 	mailer      mailer.Mailer
 	redisClient *redis.Client
+	workers     *worker.Pool
+	metrics     *metrics
+	webhooks    *webhooks.Client
+	stopTracing func(context.Context) error
+	health      *health
 	wg          sync.WaitGroup
 }
 
@@ -65,7 +100,26 @@ func main() {
 		logger.PrintFatal(err, nil)
 	}
 
-	db, err := openDB(*cfg)
+	logger.SetLevel(cfg.log.level)
+	logger.SetStackTraces(cfg.log.stackTraces)
+
+	// Route log/slog and the standard log package through the logger, so that
+	// libraries write in the same format as the application.
+	slog.SetDefault(logger.Slog())
+
+	// Tracing is set up before the connections are opened, which trace through
+	// the global tracer provider.
+	stopTracing, err := tracing.Setup(context.Background(), tracing.Config{
+		Exporter:    cfg.tracing.exporter,
+		ServiceName: "crossfitbox-api",
+		Version:     version,
+		Environment: cfg.env,
+	})
+	if err != nil {
+		logger.PrintFatal(err, nil)
+	}
+
+	db, err := env.OpenDB(cfg.db)
 	if err != nil {
 		logger.PrintFatal(err, nil)
 	}
@@ -73,6 +127,21 @@ func main() {
 
 	logger.PrintInfo("database connection pool established", nil)
 
+	if flag.Arg(0) == "migrate" {
+		err = runMigrate(db, logger, flag.Args()[1:])
+		if err != nil {
+			logger.PrintFatal(err, nil)
+		}
+		return
+	}
+
+	if cfg.migrate {
+		err = runMigrate(db, logger, []string{"up"})
+		if err != nil {
+			logger.PrintFatal(err, nil)
+		}
+	}
+
 	// sdkConfig := aws.Config{
 	// 	Region: cfg.awsConfig.Region,
 	// 	Credentials: credentials.NewStaticCredentialsProvider(
@@ -80,66 +149,70 @@ func main() {
 	// 	),
 	// }
 
-	redisClient, err := openRedis(*cfg)
+	redisClient, err := env.OpenRedis(cfg.redisURL)
 	if err != nil {
 		logger.PrintFatal(err, nil)
 	}
 
 	logger.PrintInfo("redis database connection established", nil)
 
-	app := &application{
-		config:      *cfg,
-		logger:      logger,
-		models:      data.NewModels(db),
-		mailer:      mailer.New(cfg.smtp.host, cfg.smtp.port, cfg.smtp.username, cfg.smtp.password, cfg.smtp.sender),
-		redisClient: redisClient,
-	}
-
-	err = app.serve()
+	transport, err := openMailTransport(*cfg)
 	if err != nil {
 		logger.PrintFatal(err, nil)
 	}
-}
 
-func openDB(cfg config) (*sql.DB, error) {
-	db, err := sql.Open("postgres", cfg.db.dsn)
-	if err != nil {
-		return nil, err
+	health := &health{
+		dependencies: []dependency{
+			{name: "postgres", check: db.PingContext},
+			{name: "redis", check: func(ctx context.Context) error {
+				return redisClient.Ping(ctx).Err()
+			}},
+		},
 	}
 
-	db.SetMaxOpenConns(cfg.db.maxOpenConns)
-	db.SetMaxIdleConns(cfg.db.maxIdleConns)
-	duration, err := time.ParseDuration(cfg.db.maxIdleTime)
-	if err != nil {
-		return nil, err
+	if cfg.readiness.smtp {
+		pinger, ok := transport.(mailer.Pinger)
+		if !ok {
+			logger.PrintFatal(fmt.Errorf("the %s mail transport can't be checked for readiness", cfg.mail.transport), nil)
+		}
+		health.dependencies = append(health.dependencies, dependency{name: "smtp", check: pinger.Ping})
 	}
-	db.SetConnMaxIdleTime(duration)
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
+	models := data.NewModels(db)
+	metrics := newMetrics(models, db, redisClient, logger)
 
-	err = db.PingContext(ctx)
-	if err != nil {
-		return nil, err
+	app := &application{
+		config:      *cfg,
+		logger:      logger,
+		models:      models,
+		mailer:      mailer.New(metrics.countMails(transport), cfg.smtp.sender),
+		redisClient: redisClient,
+		webhooks:    webhooks.New(10 * time.Second),
+		metrics:     metrics,
+		stopTracing: stopTracing,
+		health:      health,
 	}
 
-	return db, nil
-}
+	app.workers = worker.New(app.models, logger, cfg.jobs.workers, cfg.jobs.pollInterval, cfg.jobs.retention)
+	app.registerJobHandlers()
+	app.workers.Start()
 
-func openRedis(cfg config) (*redis.Client, error) {
-	opt, err := redis.ParseURL(cfg.redisURL)
+	err = app.serve()
 	if err != nil {
-		return nil, err
+		logger.PrintFatal(err, nil)
 	}
+}
 
-	client := redis.NewClient(opt)
-
-	ctx := context.Background()
-
-	err = client.Ping(ctx).Err()
-	if err != nil {
-
-		return nil, err
+// openMailTransport creates the mail transport selected by the -mail-transport flag.
+func openMailTransport(cfg config) (mailer.Transport, error) {
+	switch cfg.mail.transport {
+	case "smtp":
+		return mailer.NewSMTPTransport(cfg.smtp.host, cfg.smtp.port, cfg.smtp.username, cfg.smtp.password), nil
+	case "ses":
+		return mailer.NewSESTransport(cfg.mail.sesRegion, cfg.mail.sesProfile)
+	case "file":
+		return mailer.NewFileTransport(cfg.mail.dir)
+	default:
+		return nil, fmt.Errorf("unknown mail transport %q", cfg.mail.transport)
 	}
-	return client, nil
 }
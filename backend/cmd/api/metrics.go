@@ -0,0 +1,276 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"crossfitbox.booking.system/internal/data"
+	"crossfitbox.booking.system/internal/jsonlog"
+	"crossfitbox.booking.system/internal/mailer"
+	"github.com/julienschmidt/httprouter"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/redis/go-redis/v9"
+)
+
+// metricsNamespace prefixes the names of the metrics of the application.
+const metricsNamespace = "cfbox"
+
+// unmatchedRoute labels requests that matched no route, so that scanners probing
+// random paths cannot blow up the number of series.
+const unmatchedRoute = "unmatched"
+
+type metrics struct {
+	registry        *prometheus.Registry
+	requests        *prometheus.CounterVec
+	requestDuration *prometheus.HistogramVec
+	mails           *prometheus.CounterVec
+}
+
+// newMetrics registers the HTTP and mail metrics along with collectors reading
+// the database pool, the Redis pool and the job queue when scraped.
+func newMetrics(models data.Models, db *sql.DB, redisClient *redis.Client, logger *jsonlog.Logger) *metrics {
+	m := &metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "http_requests_total",
+			Help:      "Number of HTTP requests by method, route pattern and status.",
+		}, []string{"method", "route", "status"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "Latency of HTTP requests by method and route pattern.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route"}),
+		mails: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "mails_sent_total",
+			Help:      "Number of emails handed to the mail transport by result.",
+		}, []string{"result"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		collectors.NewDBStatsCollector(db, "postgres"),
+		newRedisCollector(redisClient),
+		newJobsCollector(models.Jobs, logger),
+		m.requests,
+		m.requestDuration,
+		m.mails,
+	)
+
+	// Start the mail series at zero so that rate() works from the first failure.
+	m.mails.WithLabelValues("success")
+	m.mails.WithLabelValues("failure")
+
+	return m
+}
+
+func (m *metrics) handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// The instrument() middleware records the count and latency of every request,
+// labelled by the route pattern the router matches rather than the raw path.
+func (m *metrics) instrument(router *httprouter.Router, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+
+		next.ServeHTTP(sw, r)
+
+		route := routePattern(router, r)
+
+		m.requests.WithLabelValues(r.Method, route, strconv.Itoa(sw.status)).Inc()
+		m.requestDuration.WithLabelValues(r.Method, route).Observe(time.Since(start).Seconds())
+	})
+}
+
+// routePattern rebuilds the pattern of the route matching the request, e.g.
+// /api/v1/workouts/:id, by putting the parameter names back in place of their
+// values.
+//
+// Parameter values can't simply be searched for in the path, as they may equal a
+// static segment, e.g. /api/v1/workouts/workouts. Instead every segment is probed:
+// httprouter never has a static segment and a parameter at the same position, so
+// a segment is a parameter exactly when the route still matches with the segment
+// replaced by a value no static segment can have.
+func routePattern(router *httprouter.Router, r *http.Request) string {
+	handle, params, _ := router.Lookup(r.Method, r.URL.Path)
+	if handle == nil {
+		return unmatchedRoute
+	}
+
+	if len(params) == 0 {
+		return r.URL.Path
+	}
+
+	segments := strings.Split(r.URL.Path, "/")
+	pattern := make([]string, len(segments))
+	copy(pattern, segments)
+
+	for i, segment := range segments {
+		if segment == "" {
+			continue
+		}
+
+		segments[i] = paramProbe
+		_, probed, _ := router.Lookup(r.Method, strings.Join(segments, "/"))
+		segments[i] = segment
+
+		for _, param := range probed {
+			if param.Value == paramProbe {
+				pattern[i] = ":" + param.Key
+				break
+			}
+		}
+	}
+
+	return strings.Join(pattern, "/")
+}
+
+// paramProbe is the segment routePattern() probes the router with. Static route
+// segments never contain a NUL byte.
+const paramProbe = "\x00"
+
+// countingTransport counts the messages delivered by the wrapped transport.
+type countingTransport struct {
+	mailer.Transport
+	mails *prometheus.CounterVec
+}
+
+func (m *metrics) countMails(transport mailer.Transport) mailer.Transport {
+	return countingTransport{Transport: transport, mails: m.mails}
+}
+
+func (t countingTransport) Send(ctx context.Context, msg *mailer.Message) error {
+	err := t.Transport.Send(ctx, msg)
+	if err != nil {
+		t.mails.WithLabelValues("failure").Inc()
+		return err
+	}
+
+	t.mails.WithLabelValues("success").Inc()
+	return nil
+}
+
+// redisCollector exposes the connection pool statistics of the Redis client.
+type redisCollector struct {
+	client *redis.Client
+
+	hits       *prometheus.Desc
+	misses     *prometheus.Desc
+	timeouts   *prometheus.Desc
+	totalConns *prometheus.Desc
+	idleConns  *prometheus.Desc
+	staleConns *prometheus.Desc
+}
+
+func newRedisCollector(client *redis.Client) *redisCollector {
+	desc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(metricsNamespace, "redis_pool", name), help, nil, nil)
+	}
+
+	return &redisCollector{
+		client:     client,
+		hits:       desc("hits_total", "Number of times a free connection was found in the pool."),
+		misses:     desc("misses_total", "Number of times a free connection was not found in the pool."),
+		timeouts:   desc("timeouts_total", "Number of times a wait for a connection timed out."),
+		totalConns: desc("connections", "Number of connections in the pool."),
+		idleConns:  desc("idle_connections", "Number of idle connections in the pool."),
+		staleConns: desc("stale_connections_total", "Number of stale connections removed from the pool."),
+	}
+}
+
+func (c *redisCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.hits
+	ch <- c.misses
+	ch <- c.timeouts
+	ch <- c.totalConns
+	ch <- c.idleConns
+	ch <- c.staleConns
+}
+
+func (c *redisCollector) Collect(ch chan<- prometheus.Metric) {
+	stats := c.client.PoolStats()
+
+	ch <- prometheus.MustNewConstMetric(c.hits, prometheus.CounterValue, float64(stats.Hits))
+	ch <- prometheus.MustNewConstMetric(c.misses, prometheus.CounterValue, float64(stats.Misses))
+	ch <- prometheus.MustNewConstMetric(c.timeouts, prometheus.CounterValue, float64(stats.Timeouts))
+	ch <- prometheus.MustNewConstMetric(c.totalConns, prometheus.GaugeValue, float64(stats.TotalConns))
+	ch <- prometheus.MustNewConstMetric(c.idleConns, prometheus.GaugeValue, float64(stats.IdleConns))
+	ch <- prometheus.MustNewConstMetric(c.staleConns, prometheus.CounterValue, float64(stats.StaleConns))
+}
+
+// jobsCountsTTL is how long the job counts are reused between scrapes, so that
+// frequent scrapes or several scrapers don't each run a count over the jobs table.
+const jobsCountsTTL = 30 * time.Second
+
+// jobsCollector exposes the number of background jobs by kind and status.
+type jobsCollector struct {
+	jobs   data.JobModel
+	logger *jsonlog.Logger
+	desc   *prometheus.Desc
+
+	mu        sync.Mutex
+	counts    map[string]map[string]int
+	countedAt time.Time
+}
+
+func newJobsCollector(jobs data.JobModel, logger *jsonlog.Logger) *jobsCollector {
+	return &jobsCollector{
+		jobs:   jobs,
+		logger: logger,
+		desc: prometheus.NewDesc(prometheus.BuildFQName(metricsNamespace, "", "jobs"),
+			"Number of background jobs by kind and status.", []string{"kind", "status"}, nil),
+	}
+}
+
+func (c *jobsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *jobsCollector) Collect(ch chan<- prometheus.Metric) {
+	counts, err := c.count()
+	if err != nil {
+		c.logger.PrintError(err, map[string]string{"collector": "jobs"})
+		ch <- prometheus.NewInvalidMetric(c.desc, err)
+		return
+	}
+
+	for kind, statuses := range counts {
+		for _, status := range []string{data.JobPending, data.JobRunning, data.JobCompleted, data.JobDead} {
+			ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, float64(statuses[status]), kind, status)
+		}
+	}
+}
+
+// count returns the job counts, querying the jobs table at most once per
+// jobsCountsTTL.
+func (c *jobsCollector) count() (map[string]map[string]int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.counts != nil && time.Since(c.countedAt) < jobsCountsTTL {
+		return c.counts, nil
+	}
+
+	counts, err := c.jobs.CountByKindAndStatus(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	c.counts = counts
+	c.countedAt = time.Now()
+
+	return counts, nil
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+func TestRoutePattern(t *testing.T) {
+	router := httprouter.New()
+	noop := func(http.ResponseWriter, *http.Request) {}
+
+	for _, pattern := range []string{
+		"/api/v1/workouts",
+		"/api/v1/workouts/:id",
+		"/api/v1/users/activate/:id/",
+		"/api/v1/admin/webhooks/:id/deliveries",
+		"/a/:id/x",
+		"/a/:id/x/:name",
+	} {
+		router.HandlerFunc(http.MethodGet, pattern, noop)
+	}
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/api/v1/workouts", "/api/v1/workouts"},
+		{"/api/v1/workouts/5", "/api/v1/workouts/:id"},
+		{"/api/v1/workouts/workouts", "/api/v1/workouts/:id"},
+		{"/api/v1/workouts/v1", "/api/v1/workouts/:id"},
+		{"/api/v1/users/activate/v1/", "/api/v1/users/activate/:id/"},
+		{"/api/v1/users/activate/activate/", "/api/v1/users/activate/:id/"},
+		{"/api/v1/admin/webhooks/deliveries/deliveries", "/api/v1/admin/webhooks/:id/deliveries"},
+		{"/a/x/x", "/a/:id/x"},
+		{"/a/x/x/x", "/a/:id/x/:name"},
+		{"/a/a/x/a", "/a/:id/x/:name"},
+		{"/api/v1/nothing", unmatchedRoute},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		if got := routePattern(router, r); got != tt.want {
+			t.Errorf("routePattern(%s) = %s; want %s", tt.path, got, tt.want)
+		}
+	}
+}
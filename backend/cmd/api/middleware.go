@@ -1,18 +1,163 @@
 package main
 
 import (
+	"errors"
+	"fmt"
+	"net"
 	"net/http"
+	"strings"
+	"time"
 
+	"crossfitbox.booking.system/internal/data"
+	"github.com/google/uuid"
+	"github.com/julienschmidt/httprouter"
 	"github.com/rs/cors"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// requestIDHeader carries the ID of a request. IDs sent by clients or proxies are
+// kept so that a request can be followed across services.
+const requestIDHeader = "X-Request-ID"
+
+// The traceRequests() middleware starts a server span for every request, named after
+// the matched route. Requests carrying W3C trace context headers continue the trace
+// of the caller. Monitoring requests are not traced.
+func (app *application) traceRequests(router *httprouter.Router, next http.Handler) http.Handler {
+	return otelhttp.NewHandler(next, "http.server",
+		otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+			return r.Method + " " + routePattern(router, r)
+		}),
+		otelhttp.WithFilter(func(r *http.Request) bool {
+			return !isMonitoringRequest(r)
+		}),
+	)
+}
+
+// isMonitoringRequest reports whether r is a health probe. Probes run every few
+// seconds, so they are left out of traces and their access log lines are written
+// at debug level.
+func isMonitoringRequest(r *http.Request) bool {
+	switch r.URL.Path {
+	case "/healthz", "/readyz":
+		return true
+	default:
+		return false
+	}
+}
+
+// The logRequests() middleware assigns every request an ID, returns it in the
+// X-Request-ID header and writes one access log line per request once it has
+// been served.
+func (app *application) logRequests(router *httprouter.Router, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		info := &requestInfo{id: r.Header.Get(requestIDHeader)}
+		if !validRequestID(info.id) {
+			info.id = uuid.NewString()
+		}
+
+		w.Header().Set(requestIDHeader, info.id)
+
+		logger := app.logger.With("request_id", info.id)
+
+		span := trace.SpanFromContext(r.Context())
+		if sc := span.SpanContext(); sc.IsValid() {
+			logger = logger.With("trace_id", sc.TraceID().String())
+		}
+
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+
+		r = app.contextSetRequestInfo(r, info)
+		next.ServeHTTP(sw, app.contextSetLogger(r, logger))
+
+		remoteIP, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			remoteIP = r.RemoteAddr
+		}
+
+		route := routePattern(router, r)
+
+		span.SetAttributes(
+			semconv.HTTPRoute(route),
+			attribute.String("request_id", info.id),
+		)
+
+		logAccess := logger.Info
+		if isMonitoringRequest(r) {
+			logAccess = logger.Debug
+		}
+
+		logAccess("request",
+			"method", r.Method,
+			"route", route,
+			"path", r.URL.Path,
+			"status", sw.status,
+			"duration", time.Since(start),
+			"bytes", sw.bytes,
+			"user_id", info.userID,
+			"remote_ip", remoteIP,
+		)
+	})
+}
+
+// validRequestID reports whether a request ID received from the client is safe to
+// log and echo back: at most 128 letters, digits and -_.: characters.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-', c == '_', c == '.', c == ':':
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+// statusWriter remembers the status code written by the handler and counts the
+// bytes of the body.
+type statusWriter struct {
+	http.ResponseWriter
+	status      int
+	bytes       int
+	wroteHeader bool
+}
+
+func (sw *statusWriter) WriteHeader(status int) {
+	if !sw.wroteHeader {
+		sw.status = status
+		sw.wroteHeader = true
+	}
+	sw.ResponseWriter.WriteHeader(status)
+}
+
+func (sw *statusWriter) Write(b []byte) (int, error) {
+	sw.wroteHeader = true
+	n, err := sw.ResponseWriter.Write(b)
+	sw.bytes += n
+	return n, err
+}
+
+func (sw *statusWriter) Unwrap() http.ResponseWriter {
+	return sw.ResponseWriter
+}
+
 func (app *application) recoverPanic(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		defer func() {
 			if err := recover(); err != nil {
 				w.Header().Set("connection", "close")
 
-				app.serveErrorResponse(w, r, err.(error))
+				app.serveErrorResponse(w, r, fmt.Errorf("%v", err))
 			}
 		}()
 		next.ServeHTTP(w, r)
@@ -53,3 +198,126 @@ func (app *application) enableCORS(next http.Handler) http.Handler {
 	c := cors.New(app.config.cors)
 	return c.Handler(next)
 }
+
+// The requireTenant() middleware resolves the box the request is addressed to and
+// stores it in the request context. The slug is taken from the X-Tenant header, then
+// from the subdomain of the configured base domain, and finally from the configured
+// default tenant.
+func (app *application) requireTenant(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		slug := app.tenantSlug(r)
+		if slug == "" {
+			app.tenantNotFoundResponse(w, r)
+			return
+		}
+
+		tenant, err := app.models.Tenants.GetBySlug(r.Context(), slug)
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrRecordNotFound):
+				app.tenantNotFoundResponse(w, r)
+			default:
+				app.serveErrorResponse(w, r, err)
+			}
+			return
+		}
+
+		r = app.contextSetTenant(r, tenant)
+
+		next.ServeHTTP(w, r)
+	}
+}
+
+func (app *application) tenantSlug(r *http.Request) string {
+	if slug := r.Header.Get("X-Tenant"); slug != "" {
+		return strings.ToLower(slug)
+	}
+
+	if app.config.tenant.baseDomain != "" {
+		host, _, err := net.SplitHostPort(r.Host)
+		if err != nil {
+			host = r.Host
+		}
+
+		subdomain, ok := strings.CutSuffix(strings.ToLower(host), "."+app.config.tenant.baseDomain)
+		if ok && subdomain != "" && !strings.Contains(subdomain, ".") {
+			return subdomain
+		}
+	}
+
+	return app.config.tenant.defaultSlug
+}
+
+// The requireAuthenticatedUser() middleware checks the session cookie against the
+// sessions stored in Redis and adds the logged in user to the request context. It
+// must be wrapped by requireTenant().
+func (app *application) requireAuthenticatedUser(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, status, err := app.extractParamsFromSession(r)
+		if err != nil {
+			switch *status {
+			case http.StatusUnauthorized:
+				app.unauthorizedResponse(w, r, err)
+			case http.StatusBadRequest:
+				app.badRequestResponse(w, r, err)
+			default:
+				app.serveErrorResponse(w, r, err)
+			}
+			return
+		}
+
+		_, err = app.getFromRedis(r.Context(), fmt.Sprintf("sessionid_%s", userID.Id))
+		if err != nil {
+			app.unauthorizedResponse(w, r, errors.New("you are not authorized to access this resource"))
+			return
+		}
+
+		user, err := app.models.User.Get(r.Context(), app.contextGetTenant(r).ID, userID.Id)
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrRecordNotFound):
+				app.unauthorizedResponse(w, r, errors.New("you are not authorized to access this resource"))
+			default:
+				app.serveErrorResponse(w, r, err)
+			}
+			return
+		}
+
+		r = app.contextSetUser(r, user)
+
+		next.ServeHTTP(w, r)
+	}
+}
+
+// The requireSuperuser() middleware only lets superusers of the current box through.
+func (app *application) requireSuperuser(next http.HandlerFunc) http.HandlerFunc {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		user := app.contextGetUser(r)
+
+		if !user.IsSuperuser {
+			app.notPermittedResponse(w, r)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	}
+
+	return app.requireAuthenticatedUser(fn)
+}
+
+// The requireStaffUser() middleware only lets staff members and superusers of the
+// current box through.
+func (app *application) requireStaffUser(next http.HandlerFunc) http.HandlerFunc {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		user := app.contextGetUser(r)
+
+		if !user.IsStaff && !user.IsSuperuser {
+			app.notPermittedResponse(w, r)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	}
+
+	return app.requireAuthenticatedUser(fn)
+}
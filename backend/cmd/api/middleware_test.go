@@ -0,0 +1,42 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"crossfitbox.booking.system/internal/jsonlog"
+)
+
+func TestRecoverPanic(t *testing.T) {
+	app := &application{logger: jsonlog.New(io.Discard, jsonlog.LevelOff)}
+
+	tests := []struct {
+		name  string
+		value any
+	}{
+		{"error", errors.New("boom")},
+		{"string", "missing tenant value in request context"},
+		{"other", 42},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := app.recoverPanic(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+				panic(tt.value)
+			}))
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			if rec.Code != http.StatusInternalServerError {
+				t.Errorf("status = %d, want %d", rec.Code, http.StatusInternalServerError)
+			}
+			if got := rec.Header().Get("Connection"); got != "close" {
+				t.Errorf("Connection = %q, want close", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"crossfitbox.booking.system/internal/jsonlog"
+	"crossfitbox.booking.system/internal/migrate"
+	"crossfitbox.booking.system/migrations"
+)
+
+const migrateUsage = "usage: api [flags] migrate up [N] | down [N|all] | status | force VERSION"
+
+// The runMigrate() function implements the "migrate" subcommand. up applies all
+// pending migrations (or the next N), down reverts the last migration (or the last
+// N, or all of them), status lists applied and pending migrations and force sets
+// the schema version after a failed migration was fixed by hand.
+func runMigrate(db *sql.DB, logger *jsonlog.Logger, args []string) error {
+	if len(args) == 0 || len(args) > 2 {
+		return errors.New(migrateUsage)
+	}
+
+	migrator, err := migrate.New(db, migrations.FS)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+
+	switch args[0] {
+	case "up":
+		n, err := migrateCount(args, 0)
+		if err != nil {
+			return err
+		}
+
+		applied, err := migrator.Up(ctx, n)
+		logMigrations(logger, "applied migration", applied)
+		return err
+	case "down":
+		n, err := migrateCount(args, 1)
+		if err != nil {
+			return err
+		}
+
+		reverted, err := migrator.Down(ctx, n)
+		logMigrations(logger, "reverted migration", reverted)
+		return err
+	case "status":
+		status, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintf(tw, "VERSION\tNAME\tSTATUS\n")
+		for _, m := range status.Applied {
+			state := "applied"
+			if status.Dirty && m.Version == status.Version {
+				state = "dirty"
+			}
+			fmt.Fprintf(tw, "%d\t%s\t%s\n", m.Version, m.Name, state)
+		}
+		for _, m := range status.Pending {
+			fmt.Fprintf(tw, "%d\t%s\tpending\n", m.Version, m.Name)
+		}
+		return tw.Flush()
+	case "force":
+		if len(args) != 2 {
+			return errors.New(migrateUsage)
+		}
+
+		version, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil || version < 0 {
+			return errors.New(migrateUsage)
+		}
+
+		err = migrator.Force(ctx, version)
+		if err != nil {
+			return err
+		}
+
+		logger.PrintInfo("forced schema version", map[string]string{
+			"version": args[1],
+		})
+		return nil
+	default:
+		return errors.New(migrateUsage)
+	}
+}
+
+// migrateCount parses the optional N argument of up and down. "all" and a missing
+// argument on up mean every migration.
+func migrateCount(args []string, defaultValue int) (int, error) {
+	if len(args) == 1 {
+		return defaultValue, nil
+	}
+
+	if args[1] == "all" {
+		return 0, nil
+	}
+
+	n, err := strconv.Atoi(args[1])
+	if err != nil || n < 1 {
+		return 0, errors.New(migrateUsage)
+	}
+
+	return n, nil
+}
+
+func logMigrations(logger *jsonlog.Logger, message string, list []migrate.Migration) {
+	for _, m := range list {
+		logger.PrintInfo(message, map[string]string{
+			"version": strconv.FormatInt(m.Version, 10),
+			"name":    m.Name,
+		})
+	}
+}
@@ -0,0 +1,20 @@
+package main
+
+import (
+	"io"
+	"net/http"
+
+	"crossfitbox.booking.system/internal/openapi"
+)
+
+func (app *application) openapiHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(openapi.Spec)
+}
+
+func (app *application) docsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	io.WriteString(w, openapi.DocsPage)
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"encoding/json"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"crossfitbox.booking.system/internal/data"
+	"crossfitbox.booking.system/internal/openapi"
+)
+
+type specDocument struct {
+	Paths      map[string]map[string]json.RawMessage `json:"paths"`
+	Components struct {
+		Schemas map[string]specSchema `json:"schemas"`
+	} `json:"components"`
+}
+
+type specSchema struct {
+	Ref        string                `json:"$ref"`
+	Properties map[string]specSchema `json:"properties"`
+	Items      *specSchema           `json:"items"`
+}
+
+type specOperation struct {
+	Responses map[string]struct {
+		Content map[string]struct {
+			Schema specSchema `json:"schema"`
+		} `json:"content"`
+	} `json:"responses"`
+}
+
+func loadSpec(t *testing.T) specDocument {
+	t.Helper()
+
+	var spec specDocument
+	if err := json.Unmarshal(openapi.Spec, &spec); err != nil {
+		t.Fatalf("parsing openapi.json: %v", err)
+	}
+	return spec
+}
+
+var specParam = regexp.MustCompile(`\{(\w+)\}`)
+
+// TestSpecMatchesRoutes checks that every route registered in router() is
+// documented and that every documented operation is served by the router.
+func TestSpecMatchesRoutes(t *testing.T) {
+	spec := loadSpec(t)
+
+	documented := map[string]bool{}
+	for path, item := range spec.Paths {
+		for method := range item {
+			if method == "parameters" {
+				continue
+			}
+			documented[strings.ToUpper(method)+" "+specParam.ReplaceAllString(path, ":$1")] = true
+		}
+	}
+
+	// The router can't list its routes, so they are read from the source.
+	for _, route := range registeredRoutes(t) {
+		if !documented[route] {
+			t.Errorf("%s is not documented in openapi.json", route)
+		}
+	}
+
+	// A zero application is enough: nothing is called until a request comes in.
+	app := &application{}
+	app.routes()
+
+	router := app.router()
+	for route := range documented {
+		method, path, _ := strings.Cut(route, " ")
+
+		// Parameters are filled in, so that a documented path such as
+		// /api/v1/workouts/export is matched the same way a request would be.
+		path = strings.NewReplacer(":id", "1").Replace(path)
+
+		if handle, _, _ := router.Lookup(method, path); handle == nil {
+			t.Errorf("%s is documented but not routed", route)
+		}
+	}
+}
+
+// registeredRoutes returns the "METHOD /path" of every route registered in the
+// router() method.
+func registeredRoutes(t *testing.T) []string {
+	t.Helper()
+
+	file, err := parser.ParseFile(token.NewFileSet(), "routes.go", nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var routes []string
+	for _, decl := range file.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Name.Name != "router" {
+			continue
+		}
+
+		ast.Inspect(fn.Body, func(n ast.Node) bool {
+			call, ok := n.(*ast.CallExpr)
+			if !ok || len(call.Args) < 2 {
+				return true
+			}
+			sel, ok := call.Fun.(*ast.SelectorExpr)
+			if !ok || (sel.Sel.Name != "HandlerFunc" && sel.Sel.Name != "Handler") {
+				return true
+			}
+
+			method, ok := call.Args[0].(*ast.SelectorExpr)
+			if !ok {
+				t.Fatalf("route method is not a http.Method constant: %#v", call.Args[0])
+			}
+			lit, ok := call.Args[1].(*ast.BasicLit)
+			if !ok {
+				t.Fatalf("route path is not a string literal: %#v", call.Args[1])
+			}
+			path, err := strconv.Unquote(lit.Value)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			routes = append(routes, strings.ToUpper(strings.TrimPrefix(method.Sel.Name, "Method"))+" "+path)
+			return true
+		})
+	}
+
+	if len(routes) == 0 {
+		t.Fatal("no routes found in routes.go")
+	}
+	return routes
+}
+
+// TestSpecEnvelopes checks the envelope keys documented for the workout and user
+// responses, and that the schemas inside them list the fields of the types the
+// handlers encode.
+func TestSpecEnvelopes(t *testing.T) {
+	spec := loadSpec(t)
+
+	tests := []struct {
+		method string
+		path   string
+		status string
+		keys   map[string]any
+	}{
+		{"get", "/api/v1/workouts", "200", map[string]any{"workouts": data.Workout{}, "metadata": data.Metadata{}}},
+		{"post", "/api/v1/workouts", "201", map[string]any{"workout": data.Workout{}}},
+		{"get", "/api/v1/workouts/{id}", "200", map[string]any{"workout": data.Workout{}}},
+		{"patch", "/api/v1/workouts/{id}", "200", map[string]any{"workout": data.Workout{}}},
+		{"post", "/api/v1/users/register", "201", map[string]any{"user": data.User{}}},
+		{"post", "/api/v1/users/login", "200", map[string]any{"user": data.User{}}},
+		{"get", "/api/v1/users/current-user", "200", map[string]any{"user": data.User{}}},
+	}
+
+	for _, tt := range tests {
+		name := tt.method + " " + tt.path
+		t.Run(name, func(t *testing.T) {
+			raw, ok := spec.Paths[tt.path][tt.method]
+			if !ok {
+				t.Fatalf("%s is not documented", name)
+			}
+
+			var op specOperation
+			if err := json.Unmarshal(raw, &op); err != nil {
+				t.Fatal(err)
+			}
+
+			schema := op.Responses[tt.status].Content["application/json"].Schema
+
+			var wantKeys []string
+			for key := range tt.keys {
+				wantKeys = append(wantKeys, key)
+			}
+			if got := sortedKeys(schema.Properties); !reflect.DeepEqual(got, sortedStrings(wantKeys)) {
+				t.Fatalf("envelope keys = %v, want %v", got, sortedStrings(wantKeys))
+			}
+
+			for key, value := range tt.keys {
+				prop := schema.Properties[key]
+				if prop.Items != nil {
+					prop = *prop.Items
+				}
+
+				component := spec.Components.Schemas[strings.TrimPrefix(prop.Ref, "#/components/schemas/")]
+
+				got := sortedKeys(component.Properties)
+				want := jsonFields(reflect.TypeOf(value))
+				if !reflect.DeepEqual(got, want) {
+					t.Errorf("%q fields = %v, want %v", key, got, want)
+				}
+			}
+		})
+	}
+}
+
+// jsonFields returns the sorted JSON names of the exported fields of t, as
+// encoding/json would write them with every omitempty field set.
+func jsonFields(t reflect.Type) []string {
+	var fields []string
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		switch name {
+		case "-":
+			continue
+		case "":
+			name = field.Name
+		}
+		fields = append(fields, name)
+	}
+	return sortedStrings(fields)
+}
+
+func sortedKeys(m map[string]specSchema) []string {
+	var keys []string
+	for key := range m {
+		keys = append(keys, key)
+	}
+	return sortedStrings(keys)
+}
+
+func sortedStrings(s []string) []string {
+	sort.Strings(s)
+	return s
+}
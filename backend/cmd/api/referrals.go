@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"crossfitbox.booking.system/internal/data"
+	"crossfitbox.booking.system/internal/validator"
+)
+
+// The showReferralHandler() returns the referral code and link of the logged in user
+// together with the number of members they referred.
+func (app *application) showReferralHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.contextGetUser(r)
+
+	count, err := app.models.Referrals.Count(r.Context(), user.TenantID, user.ID)
+	if err != nil {
+		app.serveErrorResponse(w, r, err)
+		return
+	}
+
+	referral := envelope{
+		"referral_code": user.ReferralCode,
+		"referral_link": app.referralLink(user.ReferralCode),
+		"referrals":     count,
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"referral": referral}, nil)
+	if err != nil {
+		app.serveErrorResponse(w, r, err)
+	}
+}
+
+// The referralLink() helper returns the link to the frontend sign up page that
+// registers new members with the referral code.
+func (app *application) referralLink(code string) string {
+	return fmt.Sprintf("%s%s?ref=%s", app.config.frontendURL, app.config.signupPath, url.QueryEscape(code))
+}
+
+func (app *application) referralReportHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		data.Filters
+	}
+
+	v := validator.New()
+
+	qs := r.URL.Query()
+
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Filters.Sort = app.readString(qs, "sort", "-referrals")
+
+	input.Filters.SortSafelist = []string{"referrals", "activated", "last_referral_at", "-referrals", "-activated", "-last_referral_at"}
+
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationErrors(w, r, v.Errors)
+		return
+	}
+
+	report, metadata, err := app.models.Referrals.GetReport(r.Context(), app.contextGetTenant(r).ID, input.Filters)
+	if err != nil {
+		app.serveErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"referrers": report, "metadata": metadata}, nil)
+	if err != nil {
+		app.serveErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,25 @@
+package main
+
+import "testing"
+
+func TestReferralLink(t *testing.T) {
+	app := &application{}
+	app.config.frontendURL = "https://box.example.com"
+
+	tests := []struct {
+		path string
+		code string
+		want string
+	}{
+		{"/auth/signup", "AB12CD34", "https://box.example.com/auth/signup?ref=AB12CD34"},
+		{"/join", "a b&c", "https://box.example.com/join?ref=a+b%26c"},
+	}
+
+	for _, tt := range tests {
+		app.config.signupPath = tt.path
+
+		if got := app.referralLink(tt.code); got != tt.want {
+			t.Errorf("referralLink(%q) with path %q = %q; want %q", tt.code, tt.path, got, tt.want)
+		}
+	}
+}
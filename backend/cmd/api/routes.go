@@ -7,6 +7,14 @@ import (
 )
 
 func (app *application) routes() http.Handler {
+	router := app.router()
+
+	return app.traceRequests(router, app.logRequests(router, app.metrics.instrument(router, app.recoverPanic(app.enableCORS(router)))))
+}
+
+// The router() registers the routes of the public API. It is kept apart from the
+// middleware in routes() so that the tests can look the routes up.
+func (app *application) router() *httprouter.Router {
 	router := httprouter.New()
 
 	router.NotFound = http.HandlerFunc(app.notFoundResponse)
@@ -14,20 +22,56 @@ func (app *application) routes() http.Handler {
 	router.MethodNotAllowed = http.HandlerFunc(app.MethodNotAllowed)
 
 	router.HandlerFunc(http.MethodGet, "/api/v1/healthcheck", app.healthcheckHandler)
+	router.HandlerFunc(http.MethodGet, "/healthz", app.livenessHandler)
+	router.HandlerFunc(http.MethodGet, "/readyz", app.readinessHandler)
+	router.HandlerFunc(http.MethodGet, "/api/v1/openapi.json", app.openapiHandler)
+	router.HandlerFunc(http.MethodGet, "/api/v1/docs", app.docsHandler)
 
 	// Workout related endpoints
-	router.HandlerFunc(http.MethodGet, "/api/v1/workouts", app.listWorkoutsHandler)
-	router.HandlerFunc(http.MethodPost, "/api/v1/workouts", app.createWorkoutHandler)
-	router.HandlerFunc(http.MethodGet, "/api/v1/workouts/:id", app.showWorkoutHandler)
-	router.HandlerFunc(http.MethodPatch, "/api/v1/workouts/:id", app.updateWorkoutHandler)
-	router.HandlerFunc(http.MethodDelete, "/api/v1/workouts/:id", app.deleteWorkoutHandler)
+	router.HandlerFunc(http.MethodGet, "/api/v1/workouts", app.requireTenant(app.listWorkoutsHandler))
+	router.HandlerFunc(http.MethodPost, "/api/v1/workouts", app.requireTenant(app.createWorkoutHandler))
+	router.HandlerFunc(http.MethodPost, "/api/v1/workouts/import", app.requireTenant(app.requireStaffUser(app.importWorkoutsHandler)))
+	router.HandlerFunc(http.MethodGet, "/api/v1/workouts/:id", app.requireTenant(app.showWorkoutOrExportHandler))
+	router.HandlerFunc(http.MethodPatch, "/api/v1/workouts/:id", app.requireTenant(app.updateWorkoutHandler))
+	router.HandlerFunc(http.MethodDelete, "/api/v1/workouts/:id", app.requireTenant(app.deleteWorkoutHandler))
 
 	// User related endpoints
-	router.HandlerFunc(http.MethodPost, "/api/v1/users/register", app.registerUserHandler)
-	router.HandlerFunc(http.MethodPost, "/api/v1/users/login", app.loginUserHandler)
-	router.HandlerFunc(http.MethodPut, "/api/v1/users/activate/:id/", app.activateUserHandler)
-	router.HandlerFunc(http.MethodGet, "/api/v1/users/current-user", app.currentUserHandler)
-	router.HandlerFunc(http.MethodPost, "/api/v1/users/logout", app.logoutUserHandler)
+	router.HandlerFunc(http.MethodPost, "/api/v1/users/register", app.requireTenant(app.registerUserHandler))
+	router.HandlerFunc(http.MethodPost, "/api/v1/users/login", app.requireTenant(app.loginUserHandler))
+	router.HandlerFunc(http.MethodPut, "/api/v1/users/activate/:id/", app.requireTenant(app.activateUserHandler))
+	router.HandlerFunc(http.MethodGet, "/api/v1/users/current-user", app.requireTenant(app.currentUserHandler))
+	router.HandlerFunc(http.MethodPost, "/api/v1/users/logout", app.requireTenant(app.logoutUserHandler))
+	router.HandlerFunc(http.MethodGet, "/api/v1/users/referral", app.requireTenant(app.requireAuthenticatedUser(app.showReferralHandler)))
+
+	// Admin endpoints
+	router.HandlerFunc(http.MethodGet, "/api/v1/admin/jobs", app.requireTenant(app.requireSuperuser(app.listJobsHandler)))
+	router.HandlerFunc(http.MethodGet, "/api/v1/admin/jobs/:id", app.requireTenant(app.requireSuperuser(app.showJobHandler)))
+	router.HandlerFunc(http.MethodPost, "/api/v1/admin/jobs/:id/replay", app.requireTenant(app.requireSuperuser(app.replayJobHandler)))
+	router.HandlerFunc(http.MethodGet, "/api/v1/admin/webhooks", app.requireTenant(app.requireSuperuser(app.listWebhooksHandler)))
+	router.HandlerFunc(http.MethodPost, "/api/v1/admin/webhooks", app.requireTenant(app.requireSuperuser(app.createWebhookHandler)))
+	router.HandlerFunc(http.MethodGet, "/api/v1/admin/webhooks/:id", app.requireTenant(app.requireSuperuser(app.showWebhookHandler)))
+	router.HandlerFunc(http.MethodPatch, "/api/v1/admin/webhooks/:id", app.requireTenant(app.requireSuperuser(app.updateWebhookHandler)))
+	router.HandlerFunc(http.MethodDelete, "/api/v1/admin/webhooks/:id", app.requireTenant(app.requireSuperuser(app.deleteWebhookHandler)))
+	router.HandlerFunc(http.MethodGet, "/api/v1/admin/webhooks/:id/deliveries", app.requireTenant(app.requireSuperuser(app.listWebhookDeliveriesHandler)))
+	router.HandlerFunc(http.MethodPost, "/api/v1/admin/webhooks/:id/test", app.requireTenant(app.requireSuperuser(app.testWebhookHandler)))
+	router.HandlerFunc(http.MethodPost, "/api/v1/admin/workouts/benchmarks", app.requireTenant(app.requireSuperuser(app.seedBenchmarksHandler)))
+	router.HandlerFunc(http.MethodGet, "/api/v1/admin/referrals", app.requireTenant(app.requireStaffUser(app.referralReportHandler)))
+
+	return router
+}
+
+// The internalRoutes() are served on the -metrics-addr port only. They concern the
+// whole process rather than a box, so they must not be reachable by the users of
+// any tenant.
+func (app *application) internalRoutes() http.Handler {
+	router := httprouter.New()
+
+	router.NotFound = http.HandlerFunc(app.notFoundResponse)
+	router.MethodNotAllowed = http.HandlerFunc(app.MethodNotAllowed)
+
+	router.Handler(http.MethodGet, "/metrics", app.metrics.handler())
+	router.HandlerFunc(http.MethodGet, "/log-level", app.showLogLevelHandler)
+	router.HandlerFunc(http.MethodPut, "/log-level", app.updateLogLevelHandler)
 
-	return app.recoverPanic(app.enableCORS(router))
+	return app.recoverPanic(router)
 }
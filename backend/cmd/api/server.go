@@ -22,6 +22,33 @@ func (app *application) serve() error {
 		WriteTimeout: 30 * time.Second,
 	}
 
+	// The metrics and the log level are served on a port of their own, never next
+	// to the public API: they aren't authenticated and cover every tenant.
+	var metricsSrv *http.Server
+	if app.config.metricsAddr != "" {
+		metricsSrv = &http.Server{
+			Addr:         app.config.metricsAddr,
+			Handler:      app.internalRoutes(),
+			ErrorLog:     log.New(app.logger, "", 0),
+			IdleTimeout:  time.Minute,
+			ReadTimeout:  10 * time.Second,
+			WriteTimeout: 30 * time.Second,
+		}
+
+		go func() {
+			app.logger.PrintInfo("starting metrics server", map[string]string{
+				"addr": metricsSrv.Addr,
+			})
+
+			err := metricsSrv.ListenAndServe()
+			if !errors.Is(err, http.ErrServerClosed) {
+				app.logger.PrintError(err, map[string]string{
+					"addr": metricsSrv.Addr,
+				})
+			}
+		}()
+	}
+
 	shutdownError := make(chan error)
 
 	go func() {
@@ -35,10 +62,39 @@ func (app *application) serve() error {
 			"signal": s.String(),
 		})
 
+		// Fail the readiness probe first and give the orchestrator time to stop
+		// routing new requests here before the listener is closed.
+		app.health.shuttingDown.Store(true)
+		time.Sleep(app.config.readiness.shutdownDelay)
+
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
 
-		shutdownError <- srv.Shutdown(ctx)
+		if metricsSrv != nil {
+			metricsSrv.Shutdown(ctx)
+		}
+
+		// A failed shutdown is reported once the background tasks are drained.
+		shutdownErr := srv.Shutdown(ctx)
+
+		app.logger.PrintInfo("completing background tasks", map[string]string{
+			"addr": srv.Addr,
+		})
+
+		// Stop claiming new jobs, then wait for the jobs and goroutines in flight.
+		app.workers.Stop()
+		app.wg.Wait()
+
+		// Flush the spans of the requests and jobs that just finished.
+		ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		err := app.stopTracing(ctx)
+		if err != nil {
+			app.logger.PrintError(err, nil)
+		}
+
+		shutdownError <- shutdownErr
 	}()
 
 	app.logger.PrintInfo("starting server", map[string]string{
@@ -0,0 +1,607 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"crossfitbox.booking.system/internal/data"
+	"crossfitbox.booking.system/internal/validator"
+	"github.com/julienschmidt/httprouter"
+	"gopkg.in/yaml.v3"
+)
+
+// Formats accepted by the workout import and produced by the export.
+const (
+	formatJSON = "json"
+	formatCSV  = "csv"
+	formatYAML = "yaml"
+)
+
+// maxImportBytes limits the size of an import, which is larger than the 1MB
+// readJSON allows for a single resource.
+const maxImportBytes = 10 << 20
+
+// csvListSeparator separates the items of the list columns of a CSV row.
+const csvListSeparator = "|"
+
+// csvColumns are the columns of a CSV export, an import may list them in any
+// order and leave out the optional ones.
+var csvColumns = []string{"name", "mode", "time_cap", "equipment", "exercises", "trainer_tips"}
+
+// workoutRecord is the part of a workout that is imported and exported.
+type workoutRecord struct {
+	Name        string       `json:"name" yaml:"name"`
+	Mode        string       `json:"mode" yaml:"mode"`
+	TimeCap     data.TimeCap `json:"time_cap,omitempty" yaml:"time_cap,omitempty"`
+	Equipment   []string     `json:"equipment,omitempty" yaml:"equipment,omitempty"`
+	Exercises   []string     `json:"exercises" yaml:"exercises"`
+	TrainerTips []string     `json:"trainer_tips,omitempty" yaml:"trainer_tips,omitempty"`
+}
+
+// workoutRow is a decoded import row, or the reason it could not be decoded.
+type workoutRow struct {
+	record workoutRecord
+	err    error
+}
+
+type importError struct {
+	Row    int               `json:"row"`
+	Name   string            `json:"name,omitempty"`
+	Errors map[string]string `json:"errors"`
+}
+
+type importReport struct {
+	Format    string        `json:"format"`
+	Mode      string        `json:"mode"`
+	DryRun    bool          `json:"dry_run"`
+	Committed bool          `json:"committed"`
+	Total     int           `json:"total"`
+	Imported  int           `json:"imported"`
+	Failed    int           `json:"failed"`
+	Errors    []importError `json:"errors"`
+}
+
+// importWorkoutsHandler creates workouts from a JSON, CSV or YAML file. Every row
+// is validated and the report lists the errors of each failed row. In the atomic
+// mode (the default) nothing is created unless every row is valid, in the
+// best_effort mode the valid rows are created. With dry_run=true nothing is
+// created, the report shows what would have happened.
+func (app *application) importWorkoutsHandler(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+
+	qs := r.URL.Query()
+
+	report := importReport{
+		Format: app.readString(qs, "format", ""),
+		Mode:   app.readString(qs, "mode", "atomic"),
+		DryRun: app.readBool(qs, "dry_run", false, v),
+		Errors: []importError{},
+	}
+
+	if report.Format == "" {
+		report.Format = formatFromContentType(r.Header.Get("Content-Type"))
+	}
+
+	v.Check(validator.In(report.Format, formatJSON, formatCSV, formatYAML), "format", "must be one of json, csv or yaml")
+	v.Check(validator.In(report.Mode, "atomic", "best_effort"), "mode", "must be atomic or best_effort")
+
+	if !v.Valid() {
+		app.failedValidationErrors(w, r, v.Errors)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportBytes)
+
+	rows, err := decodeWorkouts(report.Format, r.Body)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if v.Check(len(rows) > 0, "body", "must contain at least 1 workout"); !v.Valid() {
+		app.failedValidationErrors(w, r, v.Errors)
+		return
+	}
+
+	tenant := app.contextGetTenant(r)
+
+	// valid holds the workouts that passed validation and rowOf the row number
+	// of each of them.
+	valid := []*data.Workout{}
+	rowOf := []int{}
+
+	for i, row := range rows {
+		if row.err != nil {
+			report.Errors = append(report.Errors, importError{
+				Row:    i + 1,
+				Errors: map[string]string{"row": row.err.Error()},
+			})
+			continue
+		}
+
+		workout := &data.Workout{
+			TenantID:    tenant.ID,
+			Name:        row.record.Name,
+			Mode:        row.record.Mode,
+			TimeCap:     row.record.TimeCap,
+			Equipment:   row.record.Equipment,
+			Exercises:   row.record.Exercises,
+			TrainerTips: row.record.TrainerTips,
+			RequestID:   app.contextGetRequestID(r),
+		}
+
+		rv := validator.New()
+
+		if data.ValidateWorkout(rv, workout); !rv.Valid() {
+			for field, message := range rv.Errors {
+				rv.Errors[field] = app.translate(r, message)
+			}
+			report.Errors = append(report.Errors, importError{Row: i + 1, Name: workout.Name, Errors: rv.Errors})
+			continue
+		}
+
+		valid = append(valid, workout)
+		rowOf = append(rowOf, i+1)
+	}
+
+	atomic := report.Mode == "atomic"
+
+	// An atomic import with invalid rows still runs against the database, as a
+	// dry run, so that duplicate names are reported along with the other errors.
+	duplicates, err := app.models.Workouts.Import(r.Context(), valid, atomic, report.DryRun || (atomic && len(report.Errors) > 0))
+	if err != nil {
+		app.serveErrorResponse(w, r, err)
+		return
+	}
+
+	for _, i := range duplicates {
+		report.Errors = append(report.Errors, importError{
+			Row:    rowOf[i],
+			Name:   valid[i].Name,
+			Errors: map[string]string{"name": app.translate(r, "Workout with this name already exists")},
+		})
+	}
+
+	sort.Slice(report.Errors, func(i, j int) bool {
+		return report.Errors[i].Row < report.Errors[j].Row
+	})
+
+	report.Total = len(rows)
+	report.Failed = len(report.Errors)
+	report.Imported = len(valid) - len(duplicates)
+	report.Committed = !report.DryRun && (!atomic || report.Failed == 0)
+
+	if !report.Committed && !report.DryRun {
+		report.Imported = 0
+	}
+
+	status := http.StatusOK
+	switch {
+	case report.Committed && report.Imported > 0:
+		status = http.StatusCreated
+	case !report.Committed && !report.DryRun:
+		status = http.StatusUnprocessableEntity
+	}
+
+	err = app.writeJSON(w, status, envelope{"import": report}, nil)
+	if err != nil {
+		app.serveErrorResponse(w, r, err)
+	}
+}
+
+// exportWorkoutsHandler streams the workouts matching the filters of
+// listWorkoutsHandler as JSON, CSV or YAML, in the format the import reads.
+func (app *application) exportWorkoutsHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Format    string
+		Name      string
+		Mode      string
+		Equipment []string
+		data.Filters
+	}
+
+	v := validator.New()
+
+	qs := r.URL.Query()
+
+	input.Format = app.readString(qs, "format", formatJSON)
+	input.Name = app.readString(qs, "name", "")
+	input.Mode = app.readString(qs, "mode", "")
+	input.Equipment = app.readCSV(qs, "equipment", []string{})
+	input.Filters.Page = 1
+	input.Filters.PageSize = 100
+	input.Filters.Sort = app.readString(qs, "sort", "id")
+
+	input.Filters.SortSafelist = []string{"id", "name", "mode", "created_at", "-id", "-name", "-mode", "-created_at"}
+
+	v.Check(validator.In(input.Format, formatJSON, formatCSV, formatYAML), "format", "must be one of json, csv or yaml")
+
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationErrors(w, r, v.Errors)
+		return
+	}
+
+	tenant := app.contextGetTenant(r)
+
+	// The first page is read before anything is written so that a failing query
+	// still gets a proper error response.
+	workouts, metadata, err := app.models.Workouts.GetAll(r.Context(), tenant.ID, input.Name, input.Mode, input.Equipment, input.Filters)
+	if err != nil {
+		app.serveErrorResponse(w, r, err)
+		return
+	}
+
+	enc := newWorkoutEncoder(input.Format, w)
+
+	w.Header().Set("Content-Type", enc.contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="workouts.%s"`, input.Format))
+	w.WriteHeader(http.StatusOK)
+
+	for {
+		err = enc.write(workouts)
+		if err != nil {
+			break
+		}
+
+		if input.Filters.Page >= metadata.LastPage {
+			break
+		}
+		input.Filters.Page++
+
+		workouts, metadata, err = app.models.Workouts.GetAll(r.Context(), tenant.ID, input.Name, input.Mode, input.Equipment, input.Filters)
+		if err != nil {
+			break
+		}
+	}
+
+	if err == nil {
+		err = enc.close()
+	}
+
+	// The status line is gone by now, all that is left is to log the error and
+	// cut the export short.
+	if err != nil {
+		app.logError(r, err)
+	}
+}
+
+// showWorkoutOrExportHandler serves GET /api/v1/workouts/:id. httprouter does not
+// allow a static /api/v1/workouts/export next to the :id parameter, so the export
+// is dispatched from here.
+func (app *application) showWorkoutOrExportHandler(w http.ResponseWriter, r *http.Request) {
+	if httprouter.ParamsFromContext(r.Context()).ByName("id") == "export" {
+		app.exportWorkoutsHandler(w, r)
+		return
+	}
+
+	app.showWorkoutHandler(w, r)
+}
+
+// formatFromContentType maps the media type of an import to its format.
+func formatFromContentType(contentType string) string {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return ""
+	}
+
+	switch mediaType {
+	case "application/json":
+		return formatJSON
+	case "text/csv":
+		return formatCSV
+	case "application/yaml", "application/x-yaml", "text/yaml", "text/x-yaml":
+		return formatYAML
+	default:
+		return ""
+	}
+}
+
+// decodeWorkouts reads the rows of an import. An error is returned only when the
+// file as a whole cannot be read, a row that cannot be decoded carries its own
+// error.
+func decodeWorkouts(format string, body io.Reader) ([]workoutRow, error) {
+	switch format {
+	case formatCSV:
+		return decodeWorkoutsCSV(body)
+	case formatYAML:
+		// YAML is decoded into generic values and converted to JSON so that
+		// both formats share the rules of the JSON decoding, e.g. for time_cap.
+		var items []interface{}
+
+		err := yaml.NewDecoder(body).Decode(&items)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil, errors.New("body must not be empty")
+			}
+			return nil, errors.New("body must be a YAML list of workouts")
+		}
+
+		raw := make([]json.RawMessage, len(items))
+		for i, item := range items {
+			raw[i], err = json.Marshal(item)
+			if err != nil {
+				return nil, errors.New("body must be a YAML list of workouts")
+			}
+		}
+
+		return decodeWorkoutsJSON(raw), nil
+	default:
+		var raw []json.RawMessage
+
+		err := json.NewDecoder(body).Decode(&raw)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil, errors.New("body must not be empty")
+			}
+			return nil, errors.New("body must be a JSON array of workouts")
+		}
+
+		return decodeWorkoutsJSON(raw), nil
+	}
+}
+
+func decodeWorkoutsJSON(raw []json.RawMessage) []workoutRow {
+	rows := make([]workoutRow, len(raw))
+
+	for i, item := range raw {
+		dec := json.NewDecoder(strings.NewReader(string(item)))
+		dec.DisallowUnknownFields()
+
+		err := dec.Decode(&rows[i].record)
+		if err != nil {
+			var unmarshalTypeError *json.UnmarshalTypeError
+
+			switch {
+			case errors.As(err, &unmarshalTypeError) && unmarshalTypeError.Field == "":
+				rows[i].err = errors.New("is not a valid workout")
+			case errors.As(err, &unmarshalTypeError):
+				rows[i].err = fmt.Errorf("incorrect type for field %q", unmarshalTypeError.Field)
+			case errors.Is(err, data.ErrInvalidTimeCapFormat):
+				rows[i].err = errors.New(`time_cap must be a number of minutes or "<minutes> mins"`)
+			case strings.HasPrefix(err.Error(), "json: unknown field "):
+				rows[i].err = fmt.Errorf("unknown field %s", strings.TrimPrefix(err.Error(), "json: unknown field "))
+			default:
+				rows[i].err = errors.New("is not a valid workout")
+			}
+		}
+	}
+
+	return rows
+}
+
+// decodeWorkoutsCSV reads a CSV file whose first line names the columns. The list
+// columns hold their items separated by csvListSeparator.
+func decodeWorkoutsCSV(body io.Reader) ([]workoutRow, error) {
+	reader := csv.NewReader(body)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, errors.New("body must not be empty")
+		}
+		return nil, errors.New("body must be a CSV file with a header line")
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, column := range header {
+		column = strings.ToLower(strings.TrimSpace(column))
+		if !validator.In(column, csvColumns...) {
+			return nil, fmt.Errorf("unknown CSV column %q", column)
+		}
+		columns[column] = i
+	}
+
+	rows := []workoutRow{}
+
+	for {
+		fields, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		var row workoutRow
+
+		if err != nil {
+			var parseError *csv.ParseError
+			if !errors.As(err, &parseError) {
+				return nil, err
+			}
+			row.err = parseError.Err
+			rows = append(rows, row)
+			continue
+		}
+
+		field := func(column string) string {
+			i, ok := columns[column]
+			if !ok || i >= len(fields) {
+				return ""
+			}
+			return strings.TrimSpace(fields[i])
+		}
+
+		row.record = workoutRecord{
+			Name:        field("name"),
+			Mode:        field("mode"),
+			Equipment:   splitList(field("equipment")),
+			Exercises:   splitList(field("exercises")),
+			TrainerTips: splitList(field("trainer_tips")),
+		}
+
+		if timeCap := field("time_cap"); timeCap != "" {
+			err = row.record.TimeCap.UnmarshalJSON([]byte(timeCap))
+			if err != nil {
+				err = row.record.TimeCap.UnmarshalJSON([]byte(strconv.Quote(timeCap)))
+			}
+			if err != nil {
+				row.err = errors.New(`time_cap must be a number of minutes or "<minutes> mins"`)
+			}
+		}
+
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+// splitList splits a CSV list column, dropping empty items.
+func splitList(s string) []string {
+	if s == "" {
+		return nil
+	}
+
+	items := []string{}
+	for _, item := range strings.Split(s, csvListSeparator) {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
+
+// workoutEncoder writes an export page by page.
+type workoutEncoder struct {
+	contentType string
+	write       func(workouts []*data.Workout) error
+	close       func() error
+}
+
+func newWorkoutEncoder(format string, w io.Writer) *workoutEncoder {
+	switch format {
+	case formatCSV:
+		cw := csv.NewWriter(w)
+		header := false
+
+		return &workoutEncoder{
+			contentType: "text/csv; charset=utf-8",
+			write: func(workouts []*data.Workout) error {
+				if !header {
+					header = true
+					if err := cw.Write(csvColumns); err != nil {
+						return err
+					}
+				}
+
+				for _, workout := range workouts {
+					timeCap := ""
+					if workout.TimeCap != 0 {
+						timeCap = strconv.Itoa(int(workout.TimeCap))
+					}
+
+					err := cw.Write([]string{
+						workout.Name,
+						workout.Mode,
+						timeCap,
+						strings.Join(workout.Equipment, csvListSeparator),
+						strings.Join(workout.Exercises, csvListSeparator),
+						strings.Join(workout.TrainerTips, csvListSeparator),
+					})
+					if err != nil {
+						return err
+					}
+				}
+
+				cw.Flush()
+				return cw.Error()
+			},
+			close: func() error {
+				if !header {
+					cw.Write(csvColumns)
+				}
+				cw.Flush()
+				return cw.Error()
+			},
+		}
+	case formatYAML:
+		// Every page is marshalled as a list of its own, the concatenated
+		// lists form a single YAML list.
+		empty := true
+
+		return &workoutEncoder{
+			contentType: "application/yaml",
+			write: func(workouts []*data.Workout) error {
+				if len(workouts) == 0 {
+					return nil
+				}
+				empty = false
+
+				out, err := yaml.Marshal(toRecords(workouts))
+				if err != nil {
+					return err
+				}
+
+				_, err = w.Write(out)
+				return err
+			},
+			close: func() error {
+				if empty {
+					_, err := io.WriteString(w, "[]\n")
+					return err
+				}
+				return nil
+			},
+		}
+	default:
+		first := true
+
+		return &workoutEncoder{
+			contentType: "application/json",
+			write: func(workouts []*data.Workout) error {
+				for _, record := range toRecords(workouts) {
+					prefix := ",\n\t"
+					if first {
+						prefix = "[\n\t"
+						first = false
+					}
+
+					out, err := json.Marshal(record)
+					if err != nil {
+						return err
+					}
+
+					_, err = io.WriteString(w, prefix+string(out))
+					if err != nil {
+						return err
+					}
+				}
+
+				return nil
+			},
+			close: func() error {
+				end := "\n]\n"
+				if first {
+					end = "[]\n"
+				}
+
+				_, err := io.WriteString(w, end)
+				return err
+			},
+		}
+	}
+}
+
+func toRecords(workouts []*data.Workout) []workoutRecord {
+	records := make([]workoutRecord, len(workouts))
+
+	for i, workout := range workouts {
+		records[i] = workoutRecord{
+			Name:        workout.Name,
+			Mode:        workout.Mode,
+			TimeCap:     workout.TimeCap,
+			Equipment:   workout.Equipment,
+			Exercises:   workout.Exercises,
+			TrainerTips: workout.TrainerTips,
+		}
+	}
+
+	return records
+}
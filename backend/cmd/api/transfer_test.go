@@ -0,0 +1,219 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"crossfitbox.booking.system/internal/data"
+)
+
+func TestDecodeWorkoutsCSV(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		want    []workoutRecord
+		rowErrs []string
+		err     string
+	}{
+		{
+			name: "columns in any order and case",
+			body: "Exercises, NAME ,mode\n21-15-9 thrusters|pull-ups,Fran,For Time\n",
+			want: []workoutRecord{{Name: "Fran", Mode: "For Time", Exercises: []string{"21-15-9 thrusters", "pull-ups"}}},
+		},
+		{
+			name: "list separator drops empty items",
+			body: "name,mode,equipment,exercises,trainer_tips\nCindy,AMRAP, rings | |box ,5 pull-ups|10 push-ups|15 squats|,\n",
+			want: []workoutRecord{{Name: "Cindy", Mode: "AMRAP", Equipment: []string{"rings", "box"}, Exercises: []string{"5 pull-ups", "10 push-ups", "15 squats"}}},
+		},
+		{
+			name: "time cap as minutes and as text",
+			body: "name,mode,time_cap,exercises\nA,For Time,20,run\nB,For Time,12 mins,row\n",
+			want: []workoutRecord{
+				{Name: "A", Mode: "For Time", TimeCap: 20, Exercises: []string{"run"}},
+				{Name: "B", Mode: "For Time", TimeCap: 12, Exercises: []string{"row"}},
+			},
+		},
+		{
+			name:    "invalid time cap",
+			body:    "name,mode,time_cap,exercises\nA,For Time,twenty,run\nB,For Time,300 mins,run\n",
+			want:    []workoutRecord{{Name: "A", Mode: "For Time", Exercises: []string{"run"}}, {Name: "B", Mode: "For Time", Exercises: []string{"run"}}},
+			rowErrs: []string{`time_cap must be a number of minutes or "<minutes> mins"`, `time_cap must be a number of minutes or "<minutes> mins"`},
+		},
+		{
+			name:    "malformed rows",
+			body:    "name,mode,exercises\nA,For Time\n\"B,For Time,run\nC,AMRAP,row\n",
+			want:    []workoutRecord{{}, {}},
+			rowErrs: []string{"wrong number of fields", `extraneous or missing " in quoted-field`},
+		},
+		{
+			name: "unknown column",
+			body: "name,mode,rounds\nA,For Time,5\n",
+			err:  `unknown CSV column "rounds"`,
+		},
+		{
+			name: "empty body",
+			body: "",
+			err:  "body must not be empty",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows, err := decodeWorkouts(formatCSV, strings.NewReader(tt.body))
+			checkRows(t, rows, err, tt.want, tt.rowErrs, tt.err)
+		})
+	}
+}
+
+func TestDecodeWorkoutsJSONAndYAML(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  string
+		body    string
+		want    []workoutRecord
+		rowErrs []string
+		err     string
+	}{
+		{
+			name:   "json time cap as minutes and as text",
+			format: formatJSON,
+			body:   `[{"name":"A","mode":"For Time","time_cap":20,"exercises":["run"]},{"name":"B","mode":"For Time","time_cap":"12 mins","exercises":["row"]}]`,
+			want: []workoutRecord{
+				{Name: "A", Mode: "For Time", TimeCap: 20, Exercises: []string{"run"}},
+				{Name: "B", Mode: "For Time", TimeCap: 12, Exercises: []string{"row"}},
+			},
+		},
+		{
+			name:    "json row errors",
+			format:  formatJSON,
+			body:    `[{"name":"A","rounds":5},{"name":1},{"name":"C","time_cap":"soon"},"D",{"name":"E","mode":"AMRAP","exercises":["run"]}]`,
+			want:    []workoutRecord{{Name: "A"}, {}, {Name: "C"}, {}, {Name: "E", Mode: "AMRAP", Exercises: []string{"run"}}},
+			rowErrs: []string{`unknown field "rounds"`, `incorrect type for field "name"`, `time_cap must be a number of minutes or "<minutes> mins"`, "is not a valid workout", ""},
+		},
+		{
+			name:   "json not an array",
+			format: formatJSON,
+			body:   `{"name":"A"}`,
+			err:    "body must be a JSON array of workouts",
+		},
+		{
+			name:   "json empty body",
+			format: formatJSON,
+			body:   ``,
+			err:    "body must not be empty",
+		},
+		{
+			name:   "yaml time cap as minutes and as text",
+			format: formatYAML,
+			body:   "- name: A\n  mode: For Time\n  time_cap: 20\n  exercises: [run]\n- name: B\n  mode: For Time\n  time_cap: 12 mins\n  exercises:\n    - row\n",
+			want: []workoutRecord{
+				{Name: "A", Mode: "For Time", TimeCap: 20, Exercises: []string{"run"}},
+				{Name: "B", Mode: "For Time", TimeCap: 12, Exercises: []string{"row"}},
+			},
+		},
+		{
+			name:    "yaml row errors",
+			format:  formatYAML,
+			body:    "- name: A\n  rounds: 5\n- name: B\n  exercises: run\n",
+			want:    []workoutRecord{{Name: "A"}, {Name: "B"}},
+			rowErrs: []string{`unknown field "rounds"`, `incorrect type for field "exercises"`},
+		},
+		{
+			name:   "yaml not a list",
+			format: formatYAML,
+			body:   "name: A\n",
+			err:    "body must be a YAML list of workouts",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows, err := decodeWorkouts(tt.format, strings.NewReader(tt.body))
+			checkRows(t, rows, err, tt.want, tt.rowErrs, tt.err)
+		})
+	}
+}
+
+func TestExportImportRoundTrip(t *testing.T) {
+	workouts := []*data.Workout{
+		{Name: "Fran", Mode: "For Time", TimeCap: 10, Equipment: []string{"barbell", "pull-up bar"}, Exercises: []string{"21-15-9 thrusters", "pull-ups"}, TrainerTips: []string{"unbroken, if you can"}},
+		{Name: "Cindy", Mode: "AMRAP", Exercises: []string{"5 pull-ups", "10 push-ups", "15 air squats"}},
+		{Name: "Grace, \"the\" quick one", Mode: "For Time", TimeCap: 255, Exercises: []string{"30 clean & jerks"}},
+	}
+
+	for _, format := range []string{formatJSON, formatCSV, formatYAML} {
+		t.Run(format, func(t *testing.T) {
+			var buf bytes.Buffer
+
+			enc := newWorkoutEncoder(format, &buf)
+
+			// The export is written page by page.
+			for _, page := range [][]*data.Workout{workouts[:2], workouts[2:]} {
+				if err := enc.write(page); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := enc.close(); err != nil {
+				t.Fatal(err)
+			}
+
+			rows, err := decodeWorkouts(format, &buf)
+			checkRows(t, rows, err, toRecords(workouts), nil, "")
+		})
+
+		t.Run(format+" empty", func(t *testing.T) {
+			var buf bytes.Buffer
+
+			enc := newWorkoutEncoder(format, &buf)
+			if err := enc.close(); err != nil {
+				t.Fatal(err)
+			}
+
+			rows, err := decodeWorkouts(format, &buf)
+			checkRows(t, rows, err, nil, nil, "")
+		})
+	}
+}
+
+// checkRows compares decoded rows with the expected records and row errors, an
+// empty row error meaning the row decoded fine.
+func checkRows(t *testing.T, rows []workoutRow, err error, want []workoutRecord, rowErrs []string, wantErr string) {
+	t.Helper()
+
+	if wantErr != "" {
+		if err == nil || err.Error() != wantErr {
+			t.Fatalf("got error %v; want %q", err, wantErr)
+		}
+		return
+	}
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(rows) != len(want) {
+		t.Fatalf("got %d rows; want %d", len(rows), len(want))
+	}
+
+	for i, row := range rows {
+		wantRowErr := ""
+		if rowErrs != nil {
+			wantRowErr = rowErrs[i]
+		}
+
+		gotRowErr := ""
+		if row.err != nil {
+			gotRowErr = row.err.Error()
+		}
+
+		if gotRowErr != wantRowErr {
+			t.Errorf("row %d: got error %q; want %q", i, gotRowErr, wantRowErr)
+		}
+
+		if wantRowErr == "" && !reflect.DeepEqual(row.record, want[i]) {
+			t.Errorf("row %d: got %+v; want %+v", i, row.record, want[i])
+		}
+	}
+}
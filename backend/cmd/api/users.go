@@ -2,7 +2,6 @@ package main
 
 import (
 	"bytes"
-	"context"
 	"encoding/gob"
 	"errors"
 	"fmt"
@@ -11,16 +10,17 @@ import (
 
 	"crossfitbox.booking.system/internal/cookies"
 	"crossfitbox.booking.system/internal/data"
-	"crossfitbox.booking.system/internal/tokens"
 	"crossfitbox.booking.system/internal/validator"
 )
 
 func (app *application) registerUserHandler(w http.ResponseWriter, r *http.Request) {
 	var input struct {
-		Email     string `json:"email"`
-		FirstName string `json:"first_name"`
-		LastName  string `json:"last_name"`
-		Password  string `json:"password"`
+		Email        string `json:"email"`
+		FirstName    string `json:"first_name"`
+		LastName     string `json:"last_name"`
+		Password     string `json:"password"`
+		Locale       string `json:"locale"`
+		ReferralCode string `json:"referral_code"`
 	}
 
 	err := app.readJSON(w, r, &input)
@@ -30,9 +30,18 @@ func (app *application) registerUserHandler(w http.ResponseWriter, r *http.Reque
 	}
 
 	user := &data.User{
+		TenantID:  app.contextGetTenant(r).ID,
 		Email:     input.Email,
 		FirstName: input.FirstName,
 		LastName:  input.LastName,
+		Profile: data.UserProfile{
+			Locale: input.Locale,
+		},
+		RequestID: app.contextGetRequestID(r),
+	}
+
+	if user.Profile.Locale == "" {
+		user.Profile.Locale = app.locale(r)
 	}
 
 	err = user.Password.Set(input.Password)
@@ -48,7 +57,23 @@ func (app *application) registerUserHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	err = app.models.User.Insert(user)
+	if input.ReferralCode != "" {
+		referrer, err := app.models.User.GetByReferralCode(r.Context(), user.TenantID, input.ReferralCode)
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrRecordNotFound):
+				v.AddError("referral_code", "is invalid")
+				app.failedValidationErrors(w, r, v.Errors)
+			default:
+				app.serveErrorResponse(w, r, err)
+			}
+			return
+		}
+
+		user.ReferrerID = &referrer.ID
+	}
+
+	err = app.models.User.Insert(r.Context(), user)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrDuplicateEmail):
@@ -60,36 +85,6 @@ func (app *application) registerUserHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	otp, err := tokens.GenerateOTP()
-	if err != nil {
-		app.logger.PrintError(err, nil)
-	}
-
-	err = app.storeInRedis("activation_", otp.Hash, user.ID, app.config.tokenExpiration.duration)
-	if err != nil {
-		app.logger.PrintError(err, nil)
-	}
-
-	now := time.Now()
-	expiration := now.Add(app.config.tokenExpiration.duration)
-	exact := expiration.Format(time.RFC1123)
-
-	app.background(func() {
-		mailData := map[string]interface{}{
-			"token":       tokens.FormatOTP(otp.Secret),
-			"firstName":   user.FirstName,
-			"userID":      user.ID,
-			"frontendURL": app.config.frontendURL,
-			"expiration":  app.config.tokenExpiration.durationString,
-			"exact":       exact,
-		}
-		err = app.mailer.Send(user.Email, "user_welcome.tmpl", mailData)
-		if err != nil {
-			app.logger.PrintError(err, nil)
-		}
-		app.logger.PrintInfo(fmt.Sprintf("Email sent to %s", user.ID), nil)
-	})
-
 	err = app.writeJSON(w, http.StatusCreated, envelope{"user": user}, nil)
 	if err != nil {
 		app.serveErrorResponse(w, r, err)
@@ -113,7 +108,7 @@ func (app *application) loginUserHandler(w http.ResponseWriter, r *http.Request)
 	data.ValidateEmail(v, input.Email)
 	data.ValidatePasswordPlaintext(v, input.Password)
 
-	user, err := app.models.User.GetByEmail(input.Email, true)
+	user, err := app.models.User.GetByEmail(r.Context(), app.contextGetTenant(r).ID, input.Email, true)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
@@ -136,7 +131,8 @@ func (app *application) loginUserHandler(w http.ResponseWriter, r *http.Request)
 	}
 
 	var userID = data.UserID{
-		Id: user.ID,
+		Id:       user.ID,
+		TenantID: user.TenantID,
 	}
 
 	var buf bytes.Buffer
@@ -150,7 +146,7 @@ func (app *application) loginUserHandler(w http.ResponseWriter, r *http.Request)
 	session := buf.String()
 
 	// Store session in redis
-	err = app.storeInRedis("sessionid_", session, userID.Id, app.config.secret.sessionExpiration)
+	err = app.storeInRedis(r.Context(), "sessionid_", session, userID.Id, app.config.secret.sessionExpiration)
 	if err != nil {
 		app.logError(r, err)
 	}
@@ -194,13 +190,13 @@ func (app *application) currentUserHandler(w http.ResponseWriter, r *http.Reques
 	}
 
 	// Get session from redis
-	_, err = app.getFromRedis(fmt.Sprintf("sessionid_%s", userID.Id))
+	_, err = app.getFromRedis(r.Context(), fmt.Sprintf("sessionid_%s", userID.Id))
 	if err != nil {
 		app.unauthorizedResponse(w, r, errors.New("you are not authorized to access this resource"))
 		return
 	}
 
-	user, err := app.models.User.Get(userID.Id)
+	user, err := app.models.User.Get(r.Context(), app.contextGetTenant(r).ID, userID.Id)
 	if err != nil {
 		app.badRequestResponse(w, r, err)
 		return
@@ -230,14 +226,13 @@ func (app *application) logoutUserHandler(w http.ResponseWriter, r *http.Request
 	}
 
 	// Get session from redis
-	_, err = app.getFromRedis(fmt.Sprintf("sessionid_%s", userID.Id))
+	_, err = app.getFromRedis(r.Context(), fmt.Sprintf("sessionid_%s", userID.Id))
 	if err != nil {
 		app.unauthorizedResponse(w, r, errors.New("you are not authorized to access this resource"))
 		return
 	}
 
-	ctx := context.Background()
-	_, err = app.redisClient.Del(ctx, fmt.Sprintf("sessionid_%s", userID.Id)).Result()
+	_, err = app.redisClient.Del(r.Context(), fmt.Sprintf("sessionid_%s", userID.Id)).Result()
 	if err != nil {
 		app.serveErrorResponse(w, r, errors.New("something happened decoding cookie data"))
 		return
@@ -249,7 +244,7 @@ func (app *application) logoutUserHandler(w http.ResponseWriter, r *http.Request
 		Expires: time.Now(),
 	})
 
-	err = app.writeJSON(w, http.StatusOK, "You have successfully logged out", nil)
+	err = app.writeJSON(w, http.StatusOK, app.translate(r, "You have successfully logged out"), nil)
 	if err != nil {
 		app.serveErrorResponse(w, r, err)
 		return
@@ -0,0 +1,377 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"crossfitbox.booking.system/internal/data"
+	"crossfitbox.booking.system/internal/validator"
+	"crossfitbox.booking.system/internal/webhooks"
+	"github.com/google/uuid"
+)
+
+// Webhook deliveries are retried more often than other jobs, as receivers may be
+// down for a while.
+const webhookMaxAttempts = 8
+
+// webhookDeliveryJob is the payload of jobWebhookDelivery.
+type webhookDeliveryJob struct {
+	EndpointID uuid.UUID  `json:"endpoint_id"`
+	Event      data.Event `json:"event"`
+}
+
+// The fanOutWebhooks() job runs for every event webhooks can subscribe to and
+// queues one delivery job per subscribed endpoint, so every endpoint is retried
+// independently.
+func (app *application) fanOutWebhooks(ctx context.Context, job *data.Job) error {
+	var event data.Event
+
+	err := json.Unmarshal(job.Payload, &event)
+	if err != nil {
+		return err
+	}
+
+	endpoints, err := app.models.Webhooks.GetAll(ctx, job.TenantID, event.Type)
+	if err != nil {
+		return err
+	}
+
+	for _, endpoint := range endpoints {
+		payload, err := json.Marshal(webhookDeliveryJob{EndpointID: endpoint.ID, Event: event})
+		if err != nil {
+			return err
+		}
+
+		err = app.models.Jobs.Insert(ctx, &data.Job{
+			TenantID:    job.TenantID,
+			Kind:        jobWebhookDelivery,
+			Payload:     payload,
+			MaxAttempts: webhookMaxAttempts,
+			DedupKey:    fmt.Sprintf("%s/%s/%s", event.ID, jobWebhookDelivery, endpoint.ID),
+			RequestID:   job.RequestID,
+		})
+		if err != nil && !errors.Is(err, data.ErrDuplicateJob) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (app *application) deliverWebhookJob(ctx context.Context, job *data.Job) error {
+	var payload webhookDeliveryJob
+
+	err := json.Unmarshal(job.Payload, &payload)
+	if err != nil {
+		return err
+	}
+
+	endpoint, err := app.models.Webhooks.Get(ctx, job.TenantID, payload.EndpointID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			// The endpoint was deleted after the event happened.
+			return nil
+		default:
+			return err
+		}
+	}
+
+	if !endpoint.IsActive {
+		return nil
+	}
+
+	_, err = app.deliverWebhook(ctx, endpoint, payload.Event, job.Attempts)
+	return err
+}
+
+// The deliverWebhook() helper sends a signed event to the endpoint and records the
+// attempt in the delivery log. It returns an error unless the endpoint accepted
+// the event.
+func (app *application) deliverWebhook(ctx context.Context, endpoint *data.WebhookEndpoint, event data.Event, attempt int) (*data.WebhookDelivery, error) {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return nil, err
+	}
+
+	delivery := &data.WebhookDelivery{
+		TenantID:   endpoint.TenantID,
+		EndpointID: endpoint.ID,
+		EventID:    event.ID,
+		EventType:  event.Type,
+		Attempt:    attempt,
+	}
+
+	start := time.Now()
+
+	result, sendErr := app.webhooks.Send(ctx, endpoint.URL, endpoint.Secret, event.ID.String(), event.Type, body)
+	switch {
+	case sendErr != nil:
+		message := sendErr.Error()
+		delivery.Error = &message
+		delivery.DurationMS = int(time.Since(start).Milliseconds())
+	case !result.OK():
+		sendErr = fmt.Errorf("webhook endpoint responded with status %d", result.StatusCode)
+		fallthrough
+	default:
+		delivery.StatusCode = &result.StatusCode
+		delivery.ResponseBody = &result.ResponseBody
+		delivery.DurationMS = int(result.Duration.Milliseconds())
+	}
+
+	err = app.models.Webhooks.InsertDelivery(ctx, delivery)
+	if err != nil {
+		return nil, err
+	}
+
+	return delivery, sendErr
+}
+
+func (app *application) listWebhooksHandler(w http.ResponseWriter, r *http.Request) {
+	endpoints, err := app.models.Webhooks.GetAll(r.Context(), app.contextGetTenant(r).ID, "")
+	if err != nil {
+		app.serveErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"webhooks": endpoints}, nil)
+	if err != nil {
+		app.serveErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) createWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		URL         string   `json:"url"`
+		EventTypes  []string `json:"event_types"`
+		Description *string  `json:"description"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	endpoint := &data.WebhookEndpoint{
+		TenantID:    app.contextGetTenant(r).ID,
+		URL:         input.URL,
+		EventTypes:  input.EventTypes,
+		Description: input.Description,
+	}
+
+	v := validator.New()
+
+	if data.ValidateWebhookEndpoint(v, endpoint); !v.Valid() {
+		app.failedValidationErrors(w, r, v.Errors)
+		return
+	}
+
+	endpoint.Secret, err = webhooks.GenerateSecret()
+	if err != nil {
+		app.serveErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.models.Webhooks.Insert(r.Context(), endpoint)
+	if err != nil {
+		app.serveErrorResponse(w, r, err)
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/api/v1/admin/webhooks/%s", endpoint.ID))
+
+	// The signing secret is only returned once, when the endpoint is created.
+	err = app.writeJSON(w, http.StatusCreated, envelope{"webhook": endpoint, "secret": endpoint.Secret}, headers)
+	if err != nil {
+		app.serveErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) showWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	endpoint, ok := app.readWebhookEndpoint(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.writeJSON(w, http.StatusOK, envelope{"webhook": endpoint}, nil)
+	if err != nil {
+		app.serveErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) updateWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	endpoint, ok := app.readWebhookEndpoint(w, r)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		URL         *string  `json:"url"`
+		EventTypes  []string `json:"event_types"`
+		Description *string  `json:"description"`
+		IsActive    *bool    `json:"is_active"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if input.URL != nil {
+		endpoint.URL = *input.URL
+	}
+
+	if input.EventTypes != nil {
+		endpoint.EventTypes = input.EventTypes
+	}
+
+	if input.Description != nil {
+		endpoint.Description = input.Description
+	}
+
+	if input.IsActive != nil {
+		endpoint.IsActive = *input.IsActive
+	}
+
+	v := validator.New()
+
+	if data.ValidateWebhookEndpoint(v, endpoint); !v.Valid() {
+		app.failedValidationErrors(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Webhooks.Update(r.Context(), endpoint)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serveErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"webhook": endpoint}, nil)
+	if err != nil {
+		app.serveErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deleteWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	err = app.models.Webhooks.Delete(r.Context(), app.contextGetTenant(r).ID, *id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serveErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (app *application) listWebhookDeliveriesHandler(w http.ResponseWriter, r *http.Request) {
+	endpoint, ok := app.readWebhookEndpoint(w, r)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		data.Filters
+	}
+
+	v := validator.New()
+
+	qs := r.URL.Query()
+
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Filters.Sort = app.readString(qs, "sort", "-created_at")
+
+	input.Filters.SortSafelist = []string{"created_at", "-created_at"}
+
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationErrors(w, r, v.Errors)
+		return
+	}
+
+	deliveries, metadata, err := app.models.Webhooks.GetAllDeliveries(r.Context(), endpoint.TenantID, endpoint.ID, input.Filters)
+	if err != nil {
+		app.serveErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"deliveries": deliveries, "metadata": metadata}, nil)
+	if err != nil {
+		app.serveErrorResponse(w, r, err)
+	}
+}
+
+// The testWebhookHandler() sends a webhook.test event to the endpoint right away
+// and responds with the outcome of the delivery. The response body of the receiver
+// is left out, so the endpoint can't be used to read arbitrary URLs; it is kept
+// in the delivery log.
+func (app *application) testWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	endpoint, ok := app.readWebhookEndpoint(w, r)
+	if !ok {
+		return
+	}
+
+	event := data.Event{
+		ID:        uuid.New(),
+		Type:      data.EventWebhookTest,
+		CreatedAt: time.Now().UTC(),
+		Data:      json.RawMessage(`{"message":"This is a test event"}`),
+	}
+
+	delivery, err := app.deliverWebhook(r.Context(), endpoint, event, 1)
+	if delivery == nil {
+		app.serveErrorResponse(w, r, err)
+		return
+	}
+
+	delivery.ResponseBody = nil
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"delivery": delivery}, nil)
+	if err != nil {
+		app.serveErrorResponse(w, r, err)
+	}
+}
+
+// The readWebhookEndpoint() helper loads the endpoint named by the "id" URL
+// parameter. When it returns false the error response has already been sent.
+func (app *application) readWebhookEndpoint(w http.ResponseWriter, r *http.Request) (*data.WebhookEndpoint, bool) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return nil, false
+	}
+
+	endpoint, err := app.models.Webhooks.Get(r.Context(), app.contextGetTenant(r).ID, *id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serveErrorResponse(w, r, err)
+		}
+		return nil, false
+	}
+
+	return endpoint, true
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"net/http"
 
+	"crossfitbox.booking.system/internal/benchmarks"
 	"crossfitbox.booking.system/internal/data"
 	"crossfitbox.booking.system/internal/validator"
 )
@@ -35,7 +36,9 @@ func (app *application) listWorkoutsHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	workouts, metadata, err := app.models.Workouts.GetAll(input.Name, input.Mode, input.Equipment, input.Filters)
+	tenant := app.contextGetTenant(r)
+
+	workouts, metadata, err := app.models.Workouts.GetAll(r.Context(), tenant.ID, input.Name, input.Mode, input.Equipment, input.Filters)
 	if err != nil {
 		app.serveErrorResponse(w, r, err)
 		return
@@ -64,12 +67,14 @@ func (app *application) createWorkoutHandler(w http.ResponseWriter, r *http.Requ
 	}
 
 	workout := &data.Workout{
+		TenantID:    app.contextGetTenant(r).ID,
 		Name:        input.Name,
 		Mode:        input.Mode,
 		TimeCap:     input.TimeCap,
 		Equipment:   input.Equipment,
 		Exercises:   input.Exercises,
 		TrainerTips: input.TrainerTips,
+		RequestID:   app.contextGetRequestID(r),
 	}
 
 	v := validator.New()
@@ -79,7 +84,7 @@ func (app *application) createWorkoutHandler(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	err = app.models.Workouts.Insert(workout)
+	err = app.models.Workouts.Insert(r.Context(), workout)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrDuplicateName):
@@ -107,7 +112,7 @@ func (app *application) showWorkoutHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	workout, err := app.models.Workouts.Get(*id)
+	workout, err := app.models.Workouts.Get(r.Context(), app.contextGetTenant(r).ID, *id)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
@@ -131,7 +136,7 @@ func (app *application) updateWorkoutHandler(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	workout, err := app.models.Workouts.Get(*id)
+	workout, err := app.models.Workouts.Get(r.Context(), app.contextGetTenant(r).ID, *id)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
@@ -188,7 +193,7 @@ func (app *application) updateWorkoutHandler(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	err = app.models.Workouts.Update(workout)
+	err = app.models.Workouts.Update(r.Context(), workout)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrDuplicateName):
@@ -213,7 +218,7 @@ func (app *application) deleteWorkoutHandler(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	err = app.models.Workouts.Delete(*id)
+	err = app.models.Workouts.Delete(r.Context(), app.contextGetTenant(r).ID, *id)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
@@ -224,7 +229,19 @@ func (app *application) deleteWorkoutHandler(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	err = app.writeJSON(w, http.StatusNoContent, nil, nil)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// seedBenchmarksHandler upserts the benchmark workout library into the workouts
+// of the tenant.
+func (app *application) seedBenchmarksHandler(w http.ResponseWriter, r *http.Request) {
+	result, err := benchmarks.Seed(r.Context(), app.models.Workouts, app.contextGetTenant(r).ID)
+	if err != nil {
+		app.serveErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"benchmarks": result}, nil)
 	if err != nil {
 		app.serveErrorResponse(w, r, err)
 	}
@@ -0,0 +1,183 @@
+// Command cfboxctl runs the operational tasks that would otherwise be done by hand
+// in psql or redis-cli: creating superusers, activating and deactivating users,
+// resetting passwords, managing sessions and moving workouts between boxes.
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"crossfitbox.booking.system/internal/data"
+	"crossfitbox.booking.system/internal/env"
+	_ "github.com/lib/pq"
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/term"
+)
+
+type config struct {
+	db         env.DB
+	redisURL   string
+	tenantSlug string
+}
+
+type application struct {
+	config      config
+	tenant      *data.Tenant
+	models      data.Models
+	redisClient *redis.Client
+	in          *bufio.Reader
+	out         io.Writer
+}
+
+// command describes a subcommand. Commands that work with sessions set usesRedis
+// so that the others do not need a reachable Redis server.
+type command struct {
+	usage     string
+	help      string
+	usesRedis bool
+	run       func(app *application, args []string) error
+}
+
+var commands = map[string]command{
+	"create-superuser": {"create-superuser EMAIL FIRST_NAME LAST_NAME", "create an active superuser, reading the password from stdin", false, (*application).createSuperuser},
+	"activate":         {"activate EMAIL", "activate a user", false, (*application).activateUser},
+	"deactivate":       {"deactivate EMAIL", "deactivate a user and revoke their session", true, (*application).deactivateUser},
+	"reset-password":   {"reset-password EMAIL", "set a new password, reading it from stdin", false, (*application).resetPassword},
+	"list-users":       {"list-users", "list the users of the tenant", false, (*application).listUsers},
+	"list-sessions":    {"list-sessions", "list the open sessions of the tenant", true, (*application).listSessions},
+	"revoke-sessions":  {"revoke-sessions EMAIL", "log a user out by deleting their session", true, (*application).revokeSessions},
+	"export-workouts":  {"export-workouts [FILE]", "write the workouts of the tenant as JSON to FILE or stdout", false, (*application).exportWorkouts},
+	"import-workouts":  {"import-workouts [FILE]", "create the workouts of a JSON export read from FILE or stdin", false, (*application).importWorkouts},
+	"seed-benchmarks":  {"seed-benchmarks", "create or update the benchmark workouts of the tenant", false, (*application).seedBenchmarks},
+}
+
+func main() {
+	err := run()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "cfboxctl:", err)
+		os.Exit(1)
+	}
+}
+
+func run() error {
+	// The .env file is optional here, the variables may come from the shell.
+	_ = env.Load()
+
+	var cfg config
+
+	err := cfg.db.RegisterFlags(flag.CommandLine)
+	if err != nil {
+		return err
+	}
+
+	flag.StringVar(&cfg.redisURL, "redis-url", os.Getenv("REDIS_URL"), "Redis URL")
+	flag.StringVar(&cfg.tenantSlug, "tenant", env.DefaultTenant(), "Slug of the tenant to operate on")
+
+	flag.Usage = usage
+	flag.Parse()
+
+	cmd, ok := commands[flag.Arg(0)]
+	if !ok {
+		usage()
+		os.Exit(2)
+	}
+
+	db, err := env.OpenDB(cfg.db)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	app := &application{
+		config: cfg,
+		models: data.NewModels(db),
+		in:     bufio.NewReader(os.Stdin),
+		out:    os.Stdout,
+	}
+
+	app.tenant, err = app.models.Tenants.GetBySlug(context.Background(), cfg.tenantSlug)
+	if err != nil {
+		if errors.Is(err, data.ErrRecordNotFound) {
+			return fmt.Errorf("tenant %q not found", cfg.tenantSlug)
+		}
+		return err
+	}
+
+	if cmd.usesRedis {
+		app.redisClient, err = env.OpenRedis(cfg.redisURL)
+		if err != nil {
+			return err
+		}
+		defer app.redisClient.Close()
+	}
+
+	err = cmd.run(app, flag.Args()[1:])
+	if errors.Is(err, errUsage) {
+		return errors.New("usage: cfboxctl [flags] " + cmd.usage)
+	}
+
+	return err
+}
+
+func usage() {
+	fmt.Fprintf(flag.CommandLine.Output(), "usage: cfboxctl [flags] COMMAND [ARGS]\n\ncommands:\n")
+	for _, name := range []string{
+		"create-superuser", "activate", "deactivate", "reset-password",
+		"list-users", "list-sessions", "revoke-sessions",
+		"export-workouts", "import-workouts", "seed-benchmarks",
+	} {
+		fmt.Fprintf(flag.CommandLine.Output(), "  %-45s %s\n", commands[name].usage, commands[name].help)
+	}
+	fmt.Fprintf(flag.CommandLine.Output(), "\nflags:\n")
+	flag.PrintDefaults()
+}
+
+// readPassword reads the password from stdin so that it never shows up in the
+// shell history or the process list. On a terminal it is read without echo,
+// otherwise, e.g. when piped, from the first line of stdin.
+func (app *application) readPassword() (string, error) {
+	fmt.Fprint(os.Stderr, "password: ")
+
+	if fd := int(os.Stdin.Fd()); term.IsTerminal(fd) {
+		password, err := term.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		return string(password), err
+	}
+
+	line, err := app.in.ReadString('\n')
+	if err != nil && !(errors.Is(err, io.EOF) && line != "") {
+		return "", err
+	}
+
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// validationError flattens the errors of a validator.
+func validationError(errs map[string]string) error {
+	messages := make([]string, 0, len(errs))
+	for field, message := range errs {
+		messages = append(messages, field+" "+message)
+	}
+	sort.Strings(messages)
+
+	return errors.New(strings.Join(messages, ", "))
+}
+
+// errUsage is returned by commands called with the wrong arguments.
+var errUsage = errors.New("wrong number of arguments")
+
+// checkArgs returns errUsage unless the command got between min and max arguments.
+func checkArgs(args []string, min, max int) error {
+	if len(args) < min || len(args) > max {
+		return errUsage
+	}
+
+	return nil
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"crossfitbox.booking.system/internal/data"
+	"crossfitbox.booking.system/internal/validator"
+	"github.com/google/uuid"
+)
+
+// sessionPrefix is the prefix of the Redis keys under which the API stores the
+// session of a user.
+const sessionPrefix = "sessionid_"
+
+// findUser looks the user up by email whether or not the account is active.
+func (app *application) findUser(email string) (*data.User, error) {
+	for _, active := range []bool{true, false} {
+		user, err := app.models.User.GetByEmail(context.Background(), app.tenant.ID, email, active)
+		if err == nil {
+			return user, nil
+		} else if !errors.Is(err, data.ErrRecordNotFound) {
+			return nil, err
+		}
+	}
+
+	return nil, fmt.Errorf("user %q not found", email)
+}
+
+func (app *application) createSuperuser(args []string) error {
+	err := checkArgs(args, 3, 3)
+	if err != nil {
+		return err
+	}
+
+	user := &data.User{
+		TenantID:    app.tenant.ID,
+		Email:       args[0],
+		FirstName:   args[1],
+		LastName:    args[2],
+		IsActive:    true,
+		IsStaff:     true,
+		IsSuperuser: true,
+		Profile: data.UserProfile{
+			Locale: "en",
+		},
+	}
+
+	plaintext, err := app.readPassword()
+	if err != nil {
+		return err
+	}
+
+	err = user.Password.Set(plaintext)
+	if err != nil {
+		return err
+	}
+
+	v := validator.New()
+
+	if data.ValidateUser(v, user); !v.Valid() {
+		return validationError(v.Errors)
+	}
+
+	err = app.models.User.Insert(context.Background(), user)
+	if err != nil {
+		if errors.Is(err, data.ErrDuplicateEmail) {
+			return fmt.Errorf("a user with email %q already exists", user.Email)
+		}
+		return err
+	}
+
+	fmt.Fprintf(app.out, "created superuser %s (%s)\n", user.Email, user.ID)
+
+	return nil
+}
+
+func (app *application) activateUser(args []string) error {
+	err := checkArgs(args, 1, 1)
+	if err != nil {
+		return err
+	}
+
+	user, err := app.findUser(args[0])
+	if err != nil {
+		return err
+	}
+
+	err = app.models.User.SetActive(context.Background(), app.tenant.ID, user.ID, true)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(app.out, "activated %s\n", user.Email)
+
+	return nil
+}
+
+func (app *application) deactivateUser(args []string) error {
+	err := checkArgs(args, 1, 1)
+	if err != nil {
+		return err
+	}
+
+	user, err := app.findUser(args[0])
+	if err != nil {
+		return err
+	}
+
+	err = app.models.User.SetActive(context.Background(), app.tenant.ID, user.ID, false)
+	if err != nil {
+		return err
+	}
+
+	err = app.redisClient.Del(context.Background(), sessionPrefix+user.ID.String()).Err()
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(app.out, "deactivated %s\n", user.Email)
+
+	return nil
+}
+
+func (app *application) resetPassword(args []string) error {
+	err := checkArgs(args, 1, 1)
+	if err != nil {
+		return err
+	}
+
+	user, err := app.findUser(args[0])
+	if err != nil {
+		return err
+	}
+
+	plaintext, err := app.readPassword()
+	if err != nil {
+		return err
+	}
+
+	v := validator.New()
+
+	if data.ValidatePasswordPlaintext(v, plaintext); !v.Valid() {
+		return validationError(v.Errors)
+	}
+
+	err = user.Password.Set(plaintext)
+	if err != nil {
+		return err
+	}
+
+	err = app.models.User.UpdatePassword(context.Background(), user)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(app.out, "reset the password of %s\n", user.Email)
+
+	return nil
+}
+
+func (app *application) listUsers(args []string) error {
+	err := checkArgs(args, 0, 0)
+	if err != nil {
+		return err
+	}
+
+	filters := data.Filters{
+		Page:         1,
+		PageSize:     100,
+		Sort:         "created_at",
+		SortSafelist: []string{"created_at"},
+	}
+
+	tw := tabwriter.NewWriter(app.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "ID\tEMAIL\tNAME\tACTIVE\tSTAFF\tSUPERUSER\tCREATED\n")
+
+	for {
+		users, metadata, err := app.models.User.GetAll(context.Background(), app.tenant.ID, filters)
+		if err != nil {
+			return err
+		}
+
+		for _, u := range users {
+			fmt.Fprintf(tw, "%s\t%s\t%s %s\t%t\t%t\t%t\t%s\n", u.ID, u.Email, u.FirstName, u.LastName,
+				u.IsActive, u.IsStaff, u.IsSuperuser, u.CreatedAt.Format(time.RFC3339))
+		}
+
+		if filters.Page >= metadata.LastPage {
+			break
+		}
+		filters.Page++
+	}
+
+	return tw.Flush()
+}
+
+func (app *application) listSessions(args []string) error {
+	err := checkArgs(args, 0, 0)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+
+	tw := tabwriter.NewWriter(app.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "USER ID\tEMAIL\tEXPIRES IN\n")
+
+	iter := app.redisClient.Scan(ctx, 0, sessionPrefix+"*", 100).Iterator()
+	for iter.Next(ctx) {
+		userID, err := uuid.Parse(strings.TrimPrefix(iter.Val(), sessionPrefix))
+		if err != nil {
+			continue
+		}
+
+		// Sessions are keyed by user only, so those of other tenants are
+		// filtered out here.
+		user, err := app.models.User.Get(context.Background(), app.tenant.ID, userID)
+		if err != nil {
+			if errors.Is(err, data.ErrRecordNotFound) {
+				continue
+			}
+			return err
+		}
+
+		ttl, err := app.redisClient.TTL(ctx, iter.Val()).Result()
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", user.ID, user.Email, ttl.Round(time.Second))
+	}
+
+	if err := iter.Err(); err != nil {
+		return err
+	}
+
+	return tw.Flush()
+}
+
+func (app *application) revokeSessions(args []string) error {
+	err := checkArgs(args, 1, 1)
+	if err != nil {
+		return err
+	}
+
+	user, err := app.findUser(args[0])
+	if err != nil {
+		return err
+	}
+
+	n, err := app.redisClient.Del(context.Background(), sessionPrefix+user.ID.String()).Result()
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(app.out, "revoked %d session(s) of %s\n", n, user.Email)
+
+	return nil
+}
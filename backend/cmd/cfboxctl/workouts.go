@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"crossfitbox.booking.system/internal/benchmarks"
+	"crossfitbox.booking.system/internal/data"
+	"crossfitbox.booking.system/internal/validator"
+)
+
+// exportWorkouts writes every workout of the tenant as a JSON array in the same
+// shape the API returns them, which is what importWorkouts reads.
+func (app *application) exportWorkouts(args []string) error {
+	err := checkArgs(args, 0, 1)
+	if err != nil {
+		return err
+	}
+
+	out := app.out
+	if len(args) == 1 {
+		f, err := os.Create(args[0])
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+
+	filters := data.Filters{
+		Page:         1,
+		PageSize:     100,
+		Sort:         "name",
+		SortSafelist: []string{"name"},
+	}
+
+	all := []*data.Workout{}
+
+	for {
+		workouts, metadata, err := app.models.Workouts.GetAll(context.Background(), app.tenant.ID, "", "", []string{}, filters)
+		if err != nil {
+			return err
+		}
+
+		all = append(all, workouts...)
+
+		if filters.Page >= metadata.LastPage {
+			break
+		}
+		filters.Page++
+	}
+
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "\t")
+
+	err = enc.Encode(all)
+	if err != nil {
+		return err
+	}
+
+	if len(args) == 1 {
+		fmt.Fprintf(os.Stderr, "exported %d workout(s)\n", len(all))
+	}
+
+	return nil
+}
+
+// importWorkouts creates the workouts of a JSON export in one transaction, the way
+// the API imports them: nothing is created unless every workout is valid. Workouts
+// whose name is already taken in the tenant are skipped, so an import can be
+// rerun. Only the fields a box can set are read, so an export can't turn its
+// workouts into benchmarks.
+func (app *application) importWorkouts(args []string) error {
+	err := checkArgs(args, 0, 1)
+	if err != nil {
+		return err
+	}
+
+	var in io.Reader = app.in
+	if len(args) == 1 {
+		f, err := os.Open(args[0])
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+
+	var exported []*data.Workout
+
+	err = json.NewDecoder(in).Decode(&exported)
+	if err != nil {
+		return err
+	}
+
+	workouts := make([]*data.Workout, 0, len(exported))
+
+	for i, e := range exported {
+		workout := &data.Workout{
+			TenantID:    app.tenant.ID,
+			Name:        e.Name,
+			Mode:        e.Mode,
+			TimeCap:     e.TimeCap,
+			Equipment:   e.Equipment,
+			Exercises:   e.Exercises,
+			TrainerTips: e.TrainerTips,
+		}
+
+		v := validator.New()
+
+		if data.ValidateWorkout(v, workout); !v.Valid() {
+			return fmt.Errorf("workout %d (%q): %w", i+1, workout.Name, validationError(v.Errors))
+		}
+
+		workouts = append(workouts, workout)
+	}
+
+	skipped, err := app.models.Workouts.Import(context.Background(), workouts, false, false)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(app.out, "created %d workout(s), skipped %d existing\n", len(workouts)-len(skipped), len(skipped))
+
+	return nil
+}
+
+func (app *application) seedBenchmarks(args []string) error {
+	err := checkArgs(args, 0, 0)
+	if err != nil {
+		return err
+	}
+
+	result, err := benchmarks.Seed(context.Background(), app.models.Workouts, app.tenant.ID)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(app.out, "seeded benchmarks v%d: created %d workout(s), updated %d\n", result.Version, result.Created, result.Updated)
+
+	if len(result.Skipped) > 0 {
+		fmt.Fprintf(app.out, "skipped %d benchmark(s) whose name is taken by a workout of the box: %s\n", len(result.Skipped), strings.Join(result.Skipped, ", "))
+	}
+
+	return nil
+}
@@ -0,0 +1,86 @@
+// Package benchmarks ships the library of benchmark workouts (the Girls, the Hero
+// workouts and a selection of Open workouts) that every box can seed instead of
+// typing them in by hand.
+package benchmarks
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"crossfitbox.booking.system/internal/data"
+	"crossfitbox.booking.system/internal/validator"
+	"github.com/google/uuid"
+)
+
+// The dataset carries its own version, bump it whenever a workout is added or
+// changed so that boxes know to seed again.
+//
+//go:embed benchmarks.json
+var dataset []byte
+
+type Library struct {
+	Version  int             `json:"version"`
+	Workouts []*data.Workout `json:"workouts"`
+}
+
+// Result summarizes a seeding run. Skipped lists the benchmarks whose name is
+// taken by a workout of the box's own.
+type Result struct {
+	Version int      `json:"version"`
+	Created int      `json:"created"`
+	Updated int      `json:"updated"`
+	Skipped []string `json:"skipped"`
+}
+
+// Load decodes the embedded dataset.
+func Load() (*Library, error) {
+	var library Library
+
+	err := json.Unmarshal(dataset, &library)
+	if err != nil {
+		return nil, fmt.Errorf("benchmarks: %w", err)
+	}
+
+	return &library, nil
+}
+
+// Seed upserts the library into the workouts of the tenant by name. Benchmarks
+// seeded before are overwritten, so seeding can be repeated safely. Workouts the
+// box created itself are never touched, even when they share a benchmark's name;
+// those benchmarks are skipped.
+func Seed(ctx context.Context, workouts data.WorkoutModel, tenantID uuid.UUID) (*Result, error) {
+	library, err := Load()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Result{Version: library.Version, Skipped: []string{}}
+
+	for _, workout := range library.Workouts {
+		workout.TenantID = tenantID
+		workout.IsBenchmark = true
+
+		v := validator.New()
+
+		if data.ValidateWorkout(v, workout); !v.Valid() {
+			return nil, fmt.Errorf("benchmarks: invalid workout %q: %v", workout.Name, v.Errors)
+		}
+
+		inserted, err := workouts.Upsert(ctx, workout)
+		switch {
+		case errors.Is(err, data.ErrDuplicateName):
+			result.Skipped = append(result.Skipped, workout.Name)
+		case err != nil:
+			return nil, fmt.Errorf("benchmarks: %s: %w", workout.Name, err)
+		case inserted:
+			result.Created++
+		default:
+			result.Updated++
+		}
+	}
+
+	return result, nil
+}
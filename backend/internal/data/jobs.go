@@ -0,0 +1,356 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"crossfitbox.booking.system/internal/tracing"
+	"github.com/google/uuid"
+)
+
+// Job statuses. Jobs that exhausted their attempts are kept as "dead" so they can
+// be inspected and replayed.
+const (
+	JobPending   = "pending"
+	JobRunning   = "running"
+	JobCompleted = "completed"
+	JobDead      = "dead"
+)
+
+var (
+	ErrDuplicateJob = errors.New("duplicate job")
+)
+
+type JobModel struct {
+	DB *sql.DB
+}
+
+type Job struct {
+	ID          uuid.UUID       `json:"id"`
+	TenantID    uuid.UUID       `json:"-"`
+	Kind        string          `json:"kind"`
+	Payload     json.RawMessage `json:"payload"`
+	Status      string          `json:"status"`
+	Attempts    int             `json:"attempts"`
+	MaxAttempts int             `json:"max_attempts"`
+	DedupKey    string          `json:"-"`
+	RequestID   *string         `json:"request_id"`
+	TraceParent *string         `json:"-"`
+	LastError   *string         `json:"last_error"`
+	RunAt       time.Time       `json:"run_at"`
+	CreatedAt   time.Time       `json:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+}
+
+const jobColumns = `id, tenant_id, kind, payload, status, attempts, max_attempts, last_error, request_id, traceparent, run_at, created_at, updated_at`
+
+func (j *Job) scanArgs() []interface{} {
+	return []interface{}{
+		&j.ID,
+		&j.TenantID,
+		&j.Kind,
+		&j.Payload,
+		&j.Status,
+		&j.Attempts,
+		&j.MaxAttempts,
+		&j.LastError,
+		&j.RequestID,
+		&j.TraceParent,
+		&j.RunAt,
+		&j.CreatedAt,
+		&j.UpdatedAt,
+	}
+}
+
+// Insert enqueues a job. A zero MaxAttempts uses the table default and a zero RunAt
+// makes the job due immediately. When DedupKey is set and a job with the same key
+// was already enqueued, ErrDuplicateJob is returned. A nil TraceParent stores the
+// trace context of the span in ctx.
+func (m JobModel) Insert(ctx context.Context, job *Job) error {
+	query := `
+		INSERT INTO jobs (tenant_id, kind, payload, max_attempts, run_at, dedup_key, request_id, traceparent)
+		VALUES ($1, $2, $3, COALESCE(NULLIF($4, 0), 5), COALESCE($5, NOW()), NULLIF($6, ''), $7, NULLIF($8, ''))
+		ON CONFLICT (dedup_key) DO NOTHING
+		RETURNING ` + jobColumns
+
+	var runAt *time.Time
+	if !job.RunAt.IsZero() {
+		runAt = &job.RunAt
+	}
+
+	payload := "{}"
+	if len(job.Payload) > 0 {
+		payload = string(job.Payload)
+	}
+
+	traceParent := tracing.TraceParent(ctx)
+	if job.TraceParent != nil {
+		traceParent = *job.TraceParent
+	}
+
+	args := []interface{}{
+		job.TenantID,
+		job.Kind,
+		payload,
+		job.MaxAttempts,
+		runAt,
+		job.DedupKey,
+		job.RequestID,
+		traceParent,
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(job.scanArgs()...)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrDuplicateJob
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+// Claim locks the next due job and marks it as running. Jobs left running for
+// longer than lease, e.g. because the instance processing them crashed, are
+// claimed again. SKIP LOCKED lets several instances poll the table concurrently
+// without handing out the same job twice. ErrRecordNotFound is returned when no
+// job is due.
+func (m JobModel) Claim(ctx context.Context, lease time.Duration) (*Job, error) {
+	query := `
+		UPDATE jobs
+		SET status = 'running', attempts = attempts + 1, locked_at = NOW(), updated_at = NOW()
+		WHERE id = (
+			SELECT id
+			FROM jobs
+			WHERE (status = 'pending' AND run_at <= NOW())
+			OR (status = 'running' AND locked_at < NOW() - make_interval(secs => $1))
+			ORDER BY run_at
+			FOR UPDATE SKIP LOCKED
+			LIMIT 1
+		)
+		RETURNING ` + jobColumns
+
+	var job Job
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, lease.Seconds()).Scan(job.scanArgs()...)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &job, nil
+}
+
+func (m JobModel) Complete(ctx context.Context, job *Job) error {
+	query := `
+		UPDATE jobs
+		SET status = 'completed', last_error = NULL, locked_at = NULL, updated_at = NOW()
+		WHERE id = $1
+		RETURNING status, updated_at`
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+
+	defer cancel()
+
+	return m.DB.QueryRowContext(ctx, query, job.ID).Scan(&job.Status, &job.UpdatedAt)
+}
+
+// Fail records a failed attempt. The job is rescheduled for retryAt, or moved to
+// the dead letter state once it has used all of its attempts.
+func (m JobModel) Fail(ctx context.Context, job *Job, jobErr error, retryAt time.Time) error {
+	query := `
+		UPDATE jobs
+		SET status = CASE WHEN attempts >= max_attempts THEN 'dead' ELSE 'pending' END,
+			last_error = $2, run_at = $3, locked_at = NULL, updated_at = NOW()
+		WHERE id = $1
+		RETURNING status, last_error, run_at, updated_at`
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+
+	defer cancel()
+
+	return m.DB.QueryRowContext(ctx, query, job.ID, jobErr.Error(), retryAt).
+		Scan(&job.Status, &job.LastError, &job.RunAt, &job.UpdatedAt)
+}
+
+// DeleteCompleted deletes up to limit jobs completed before the given time and
+// returns the number of jobs deleted. Dead jobs are kept until they are replayed.
+func (m JobModel) DeleteCompleted(ctx context.Context, before time.Time, limit int) (int64, error) {
+	query := `
+		DELETE FROM jobs
+		WHERE id IN (
+			SELECT id
+			FROM jobs
+			WHERE status = 'completed' AND updated_at < $1
+			LIMIT $2
+		)`
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, before, limit)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+// Replay resets a dead job so it is picked up again with a fresh set of attempts.
+func (m JobModel) Replay(ctx context.Context, tenantID, id uuid.UUID) (*Job, error) {
+	query := `
+		UPDATE jobs
+		SET status = 'pending', attempts = 0, run_at = NOW(), locked_at = NULL, updated_at = NOW()
+		WHERE id = $1 AND tenant_id = $2 AND status = 'dead'
+		RETURNING ` + jobColumns
+
+	var job Job
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, id, tenantID).Scan(job.scanArgs()...)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &job, nil
+}
+
+func (m JobModel) Get(ctx context.Context, tenantID, id uuid.UUID) (*Job, error) {
+	query := `
+	SELECT ` + jobColumns + `
+	FROM jobs
+	WHERE id = $1 AND tenant_id = $2`
+
+	var job Job
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, id, tenantID).Scan(job.scanArgs()...)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &job, nil
+}
+
+// CountByKindAndStatus returns the number of jobs of every tenant by kind and
+// status, the outer key being the kind.
+func (m JobModel) CountByKindAndStatus(ctx context.Context) (map[string]map[string]int, error) {
+	query := `
+	SELECT kind, status, count(*)
+	FROM jobs
+	GROUP BY kind, status`
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	counts := map[string]map[string]int{}
+
+	for rows.Next() {
+		var kind, status string
+		var count int
+
+		err := rows.Scan(&kind, &status, &count)
+		if err != nil {
+			return nil, err
+		}
+
+		if counts[kind] == nil {
+			counts[kind] = map[string]int{}
+		}
+		counts[kind][status] = count
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return counts, nil
+}
+
+func (m JobModel) GetAll(ctx context.Context, tenantID uuid.UUID, kind, status string, filters Filters) ([]*Job, Metadata, error) {
+	query := fmt.Sprintf(`
+	SELECT count(*) OVER(), `+jobColumns+`
+	FROM jobs
+	WHERE tenant_id = $1
+	AND (kind = $2 OR $2 = '')
+	AND (status = $3 OR $3 = '')
+	ORDER BY %s %s, id ASC
+	LIMIT $4 OFFSET $5`, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+
+	defer cancel()
+
+	args := []interface{}{tenantID, kind, status, filters.limit(), filters.offset()}
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	defer rows.Close()
+
+	totalRecords := 0
+	jobs := []*Job{}
+
+	for rows.Next() {
+		var job Job
+
+		err := rows.Scan(append([]interface{}{&totalRecords}, job.scanArgs()...)...)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		jobs = append(jobs, &job)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return jobs, metadata, nil
+}
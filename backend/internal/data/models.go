@@ -11,13 +11,23 @@ var (
 )
 
 type Models struct {
-	Workouts WorkoutModel
-	User     UserModel
+	Tenants   TenantModel
+	Workouts  WorkoutModel
+	User      UserModel
+	Jobs      JobModel
+	Outbox    OutboxModel
+	Webhooks  WebhookModel
+	Referrals ReferralModel
 }
 
 func NewModels(db *sql.DB) Models {
 	return Models{
-		Workouts: WorkoutModel{DB: db},
-		User:     UserModel{DB: db},
+		Tenants:   TenantModel{DB: db},
+		Workouts:  WorkoutModel{DB: db},
+		User:      UserModel{DB: db},
+		Jobs:      JobModel{DB: db},
+		Outbox:    OutboxModel{DB: db},
+		Webhooks:  WebhookModel{DB: db},
+		Referrals: ReferralModel{DB: db},
 	}
 }
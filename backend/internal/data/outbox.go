@@ -0,0 +1,181 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"crossfitbox.booking.system/internal/tracing"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// Domain events written to the outbox.
+const (
+	EventUserRegistered = "user.registered"
+	EventWorkoutCreated = "workout.created"
+)
+
+// Event is the envelope of an outbox event. It is the payload of every job created
+// from the outbox, with the event specific payload in Data.
+type Event struct {
+	ID        uuid.UUID       `json:"id"`
+	Type      string          `json:"type"`
+	CreatedAt time.Time       `json:"created_at"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// UserRegisteredEvent is the payload of EventUserRegistered.
+type UserRegisteredEvent struct {
+	UserID     uuid.UUID  `json:"user_id"`
+	Email      string     `json:"email"`
+	FirstName  string     `json:"first_name"`
+	Locale     string     `json:"locale"`
+	ReferrerID *uuid.UUID `json:"referrer_id,omitempty"`
+}
+
+type OutboxModel struct {
+	DB *sql.DB
+}
+
+// insertOutboxEvent records an event inside tx, so the event is stored if and only
+// if the change it describes is committed. requestID is the ID of the request that
+// caused the event, if any, and the trace context of the span in ctx is kept with
+// the event.
+func insertOutboxEvent(ctx context.Context, tx *sql.Tx, tenantID uuid.UUID, eventType string, aggregateID uuid.UUID, requestID string, payload interface{}) error {
+	js, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO outbox (tenant_id, event_type, aggregate_id, payload, request_id, traceparent)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), NULLIF($6, ''))`
+
+	_, err = tx.ExecContext(ctx, query, tenantID, eventType, aggregateID, string(js), requestID, tracing.TraceParent(ctx))
+	return err
+}
+
+// Dispatch hands up to limit pending events over to the job queue. Every job kind
+// subscribed to an event type gets one job per event, carrying the Event.
+// The jobs are created and the events marked as dispatched in one transaction, and
+// the jobs are keyed by event and kind, so an event is delivered at least once to
+// each subscriber but never queued twice for the same one. It returns the number
+// of events dispatched.
+func (m OutboxModel) Dispatch(ctx context.Context, subscriptions map[string][]string, limit int) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	query := `
+		SELECT id, tenant_id, event_type, payload, created_at, request_id, traceparent
+		FROM outbox
+		WHERE dispatched_at IS NULL
+		ORDER BY created_at
+		LIMIT $1
+		FOR UPDATE SKIP LOCKED`
+
+	rows, err := tx.QueryContext(ctx, query, limit)
+	if err != nil {
+		return 0, err
+	}
+
+	type event struct {
+		Event
+		tenantID    uuid.UUID
+		requestID   *string
+		traceParent *string
+	}
+
+	var events []event
+
+	for rows.Next() {
+		var e event
+
+		err := rows.Scan(&e.ID, &e.tenantID, &e.Type, &e.Data, &e.CreatedAt, &e.requestID, &e.traceParent)
+		if err != nil {
+			rows.Close()
+			return 0, err
+		}
+
+		events = append(events, e)
+	}
+
+	rows.Close()
+
+	if err = rows.Err(); err != nil {
+		return 0, err
+	}
+
+	if len(events) == 0 {
+		return 0, nil
+	}
+
+	query = `
+		INSERT INTO jobs (tenant_id, kind, payload, dedup_key, request_id, traceparent)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (dedup_key) DO NOTHING`
+
+	ids := make([]uuid.UUID, 0, len(events))
+
+	for _, e := range events {
+		payload, err := json.Marshal(e.Event)
+		if err != nil {
+			return 0, err
+		}
+
+		for _, kind := range subscriptions[e.Type] {
+			dedupKey := e.ID.String() + "/" + kind
+
+			_, err := tx.ExecContext(ctx, query, e.tenantID, kind, string(payload), dedupKey, e.requestID, e.traceParent)
+			if err != nil {
+				return 0, err
+			}
+		}
+
+		ids = append(ids, e.ID)
+	}
+
+	query = `UPDATE outbox SET dispatched_at = NOW() WHERE id = ANY($1)`
+
+	_, err = tx.ExecContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return 0, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, err
+	}
+
+	return len(events), nil
+}
+
+// DeleteDispatched deletes up to limit events dispatched before the given time and
+// returns the number of events deleted.
+func (m OutboxModel) DeleteDispatched(ctx context.Context, before time.Time, limit int) (int64, error) {
+	query := `
+		DELETE FROM outbox
+		WHERE id IN (
+			SELECT id
+			FROM outbox
+			WHERE dispatched_at < $1
+			LIMIT $2
+		)`
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, before, limit)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
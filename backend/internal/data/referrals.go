@@ -0,0 +1,107 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type ReferralModel struct {
+	DB *sql.DB
+}
+
+// ReferrerStats summarizes the registrations brought in by one member.
+type ReferrerStats struct {
+	ReferrerID     uuid.UUID `json:"referrer_id"`
+	Email          string    `json:"email"`
+	FirstName      string    `json:"first_name"`
+	LastName       string    `json:"last_name"`
+	ReferralCode   string    `json:"referral_code"`
+	Referrals      int       `json:"referrals"`
+	Activated      int       `json:"activated"`
+	LastReferralAt time.Time `json:"last_referral_at"`
+}
+
+// Count returns the number of registrations referred by the user.
+func (m ReferralModel) Count(ctx context.Context, tenantID, referrerID uuid.UUID) (int, error) {
+	query := `
+	SELECT count(*)
+	FROM referrals
+	WHERE tenant_id = $1 AND referrer_id = $2`
+
+	var count int
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, tenantID, referrerID).Scan(&count)
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// GetReport returns the referrers of a tenant with the number of members they
+// referred and how many of those activated their account.
+func (m ReferralModel) GetReport(ctx context.Context, tenantID uuid.UUID, filters Filters) ([]*ReferrerStats, Metadata, error) {
+	query := fmt.Sprintf(`
+	SELECT count(*) OVER(), u.id, u.email, u.first_name, u.last_name, u.referral_code,
+		count(r.id) AS referrals,
+		count(r.id) FILTER (WHERE referred.is_active) AS activated,
+		max(r.created_at) AS last_referral_at
+	FROM referrals r
+	JOIN users u ON u.id = r.referrer_id
+	JOIN users referred ON referred.id = r.referred_id
+	WHERE r.tenant_id = $1
+	GROUP BY u.id
+	ORDER BY %s %s, u.id ASC
+	LIMIT $2 OFFSET $3`, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, tenantID, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	defer rows.Close()
+
+	totalRecords := 0
+	report := []*ReferrerStats{}
+
+	for rows.Next() {
+		var stats ReferrerStats
+
+		err := rows.Scan(
+			&totalRecords,
+			&stats.ReferrerID,
+			&stats.Email,
+			&stats.FirstName,
+			&stats.LastName,
+			&stats.ReferralCode,
+			&stats.Referrals,
+			&stats.Activated,
+			&stats.LastReferralAt,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		report = append(report, &stats)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return report, metadata, nil
+}
@@ -0,0 +1,97 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"regexp"
+	"time"
+
+	"crossfitbox.booking.system/internal/validator"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrDuplicateSlug = errors.New("duplicate slug")
+)
+
+// SlugRX matches tenant slugs usable as a subdomain label.
+var SlugRX = regexp.MustCompile(`^[a-z0-9](?:[a-z0-9-]{0,61}[a-z0-9])?$`)
+
+type TenantModel struct {
+	DB *sql.DB
+}
+
+// Tenant is a single box hosted on the deployment. Every tenant scoped row
+// (users, workouts, ...) references it through its tenant_id column.
+type Tenant struct {
+	ID        uuid.UUID `json:"id"`
+	Slug      string    `json:"slug"`
+	Name      string    `json:"name"`
+	IsActive  bool      `json:"is_active"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (t TenantModel) Insert(ctx context.Context, tenant *Tenant) error {
+	query := `
+		INSERT INTO tenants (slug, name)
+		VALUES ($1, $2)
+		RETURNING id, is_active, created_at`
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+
+	defer cancel()
+
+	err := t.DB.QueryRowContext(ctx, query, tenant.Slug, tenant.Name).
+		Scan(&tenant.ID, &tenant.IsActive, &tenant.CreatedAt)
+	if err != nil {
+		switch {
+		case err.Error() == `pq: duplicate key value violates unique constraint "tenants_slug_key"`:
+			return ErrDuplicateSlug
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+// GetBySlug returns the active tenant with the given slug.
+func (t TenantModel) GetBySlug(ctx context.Context, slug string) (*Tenant, error) {
+	query := `
+	SELECT id, slug, name, is_active, created_at
+	FROM tenants
+	WHERE slug = $1 AND is_active = true`
+
+	var tenant Tenant
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+
+	defer cancel()
+
+	err := t.DB.QueryRowContext(ctx, query, slug).Scan(
+		&tenant.ID,
+		&tenant.Slug,
+		&tenant.Name,
+		&tenant.IsActive,
+		&tenant.CreatedAt,
+	)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &tenant, nil
+}
+
+func ValidateTenant(v *validator.Validator, tenant *Tenant) {
+	v.Check(tenant.Name != "", "name", "must be provided")
+	v.Check(len(tenant.Name) <= 500, "name", "must not be more than 500 bytes long")
+
+	v.Check(tenant.Slug != "", "slug", "must be provided")
+	v.Check(validator.Matches(tenant.Slug, SlugRX), "slug", "must contain only lowercase letters, digits and hyphens")
+}
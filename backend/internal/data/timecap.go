@@ -10,7 +10,16 @@ var ErrInvalidTimeCapFormat = errors.New("invalid TimeCap format")
 
 type TimeCap uint8
 
+// UnmarshalJSON accepts "<minutes> mins" as well as the bare number of minutes
+// the API writes, so that exported workouts can be imported again. Both forms are
+// part of the public API: every request taking a time cap accepts either, as the
+// TimeCap schema of the OpenAPI document says.
 func (tc *TimeCap) UnmarshalJSON(jsonValue []byte) error {
+	if i, err := strconv.ParseUint(string(jsonValue), 10, 8); err == nil {
+		*tc = TimeCap(i)
+		return nil
+	}
+
 	unqoutedJSONValue, err := strconv.Unquote(string(jsonValue))
 	if err != nil {
 		return ErrInvalidTimeCapFormat
@@ -22,7 +31,7 @@ func (tc *TimeCap) UnmarshalJSON(jsonValue []byte) error {
 		return ErrInvalidTimeCapFormat
 	}
 
-	i, err := strconv.ParseInt(parts[0], 10, 32)
+	i, err := strconv.ParseUint(parts[0], 10, 8)
 	if err != nil {
 		return ErrInvalidTimeCapFormat
 	}
@@ -4,8 +4,10 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 	"time"
 
+	"crossfitbox.booking.system/internal/i18n"
 	"crossfitbox.booking.system/internal/types"
 	"crossfitbox.booking.system/internal/validator"
 	"github.com/google/uuid"
@@ -21,17 +23,21 @@ type UserModel struct {
 }
 
 type User struct {
-	ID          uuid.UUID   `json:"id"`
-	Email       string      `json:"email"`
-	Password    password    `json:"-"`
-	FirstName   string      `json:"first_name"`
-	LastName    string      `json:"last_name"`
-	IsActive    bool        `json:"is_active"`
-	IsStaff     bool        `json:"is_staff"`
-	IsSuperuser bool        `json:"is_superuser"`
-	Thumbnail   *string     `json:"thumbnail"`
-	CreatedAt   time.Time   `json:"created_at"`
-	Profile     UserProfile `json:"profile"`
+	ID           uuid.UUID   `json:"id"`
+	TenantID     uuid.UUID   `json:"-"`
+	Email        string      `json:"email"`
+	Password     password    `json:"-"`
+	FirstName    string      `json:"first_name"`
+	LastName     string      `json:"last_name"`
+	IsActive     bool        `json:"is_active"`
+	IsStaff      bool        `json:"is_staff"`
+	IsSuperuser  bool        `json:"is_superuser"`
+	Thumbnail    *string     `json:"thumbnail"`
+	CreatedAt    time.Time   `json:"created_at"`
+	ReferralCode string      `json:"referral_code"`
+	ReferrerID   *uuid.UUID  `json:"-"`
+	RequestID    string      `json:"-"`
+	Profile      UserProfile `json:"profile"`
 }
 
 type UserProfile struct {
@@ -39,20 +45,31 @@ type UserProfile struct {
 	UserID      *uuid.UUID     `json:"user_id"`
 	PhoneNumber *string        `json:"phone_number"`
 	BirthDate   types.NullTime `json:"birth_date"`
+	Locale      string         `json:"locale"`
 }
 
+// UserID is the content of a session. TenantID binds the session to the box the
+// user logged in to.
 type UserID struct {
-	Id uuid.UUID
+	Id       uuid.UUID
+	TenantID uuid.UUID
 }
 
+// userColumns lists the users and user_profile columns in the order they
+// are scanned by Get and GetByEmail.
+const userColumns = `
+	u.id, u.tenant_id, u.email, u.password, u.first_name, u.last_name,
+	u.is_active, u.is_staff, u.is_superuser, u.thumbnail, u.created_at, u.referral_code,
+	p.id, p.user_id, p.phone_number, p.birth_date, p.locale`
+
 type password struct {
 	plaintext *string
 	hash      []byte
 }
 
-func (um *UserModel) Insert(user *User) error {
+func (um *UserModel) Insert(ctx context.Context, user *User) error {
 	// TODO: return also user Id, will be useful later
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
 	defer cancel()
 
 	tx, err := um.DB.BeginTx(ctx, nil)
@@ -61,24 +78,29 @@ func (um *UserModel) Insert(user *User) error {
 	}
 
 	query_user := `
-	INSERT INTO users (email, password, first_name, last_name) 
-	VALUES ($1, $2, $3, $4) 
-	RETURNING id, created_at`
+	INSERT INTO users (tenant_id, email, password, first_name, last_name, is_active, is_staff, is_superuser) 
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8) 
+	RETURNING id, created_at, referral_code`
 
 	args_user := []interface{}{
+		user.TenantID,
 		user.Email,
 		user.Password.hash,
 		user.FirstName,
 		user.LastName,
+		user.IsActive,
+		user.IsStaff,
+		user.IsSuperuser,
 	}
 
 	err = tx.QueryRowContext(ctx, query_user, args_user...).Scan(
 		&user.ID,
 		&user.CreatedAt,
+		&user.ReferralCode,
 	)
 	if err != nil {
 		switch {
-		case err.Error() == `pq: duplicate key value violates unique constraint "users_email_key"`:
+		case err.Error() == `pq: duplicate key value violates unique constraint "users_tenant_id_email_key"`:
 			return ErrDuplicateEmail
 		default:
 			return err
@@ -86,36 +108,62 @@ func (um *UserModel) Insert(user *User) error {
 	}
 
 	query_user_profile := `
-	INSERT INTO user_profile (user_id)
-	VALUES ($1)
-	ON CONFLICT (user_id) DO NOTHING RETURNING id, user_id`
+	INSERT INTO user_profile (user_id, locale)
+	VALUES ($1, $2)
+	ON CONFLICT (user_id) DO NOTHING RETURNING id, user_id, locale`
 
-	err = tx.QueryRowContext(ctx, query_user_profile, user.ID).Scan(
+	err = tx.QueryRowContext(ctx, query_user_profile, user.ID, user.Profile.Locale).Scan(
 		&user.Profile.ID,
 		&user.Profile.UserID,
+		&user.Profile.Locale,
 	)
 	if err != nil {
 		return err
 	}
 
+	if user.ReferrerID != nil {
+		query_referral := `
+		INSERT INTO referrals (tenant_id, referrer_id, referred_id)
+		VALUES ($1, $2, $3)`
+
+		_, err = tx.ExecContext(ctx, query_referral, user.TenantID, user.ReferrerID, user.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	event := UserRegisteredEvent{
+		UserID:     user.ID,
+		Email:      user.Email,
+		FirstName:  user.FirstName,
+		Locale:     user.Profile.Locale,
+		ReferrerID: user.ReferrerID,
+	}
+
+	err = insertOutboxEvent(ctx, tx, user.TenantID, EventUserRegistered, user.ID, user.RequestID, event)
+	if err != nil {
+		return err
+	}
+
 	return tx.Commit()
 }
 
-func (um *UserModel) Get(id uuid.UUID) (*User, error) {
+func (um *UserModel) Get(ctx context.Context, tenantID, id uuid.UUID) (*User, error) {
 	query := `
-	SELECT u.*, p.*
+	SELECT ` + userColumns + `
 	FROM users u
 	JOIN user_profile p ON p.user_id = u.id
-	WHERE u.is_active = true AND u.id = $1`
+	WHERE u.is_active = true AND u.id = $1 AND u.tenant_id = $2`
 
 	var user User
 	var userProfile UserProfile
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
 	defer cancel()
 
-	err := um.DB.QueryRowContext(ctx, query, id).Scan(
+	err := um.DB.QueryRowContext(ctx, query, id, tenantID).Scan(
 		&user.ID,
+		&user.TenantID,
 		&user.Email,
 		&user.Password.hash,
 		&user.FirstName,
@@ -125,10 +173,12 @@ func (um *UserModel) Get(id uuid.UUID) (*User, error) {
 		&user.IsSuperuser,
 		&user.Thumbnail,
 		&user.CreatedAt,
+		&user.ReferralCode,
 		&userProfile.ID,
 		&userProfile.UserID,
 		&userProfile.PhoneNumber,
 		&userProfile.BirthDate,
+		&userProfile.Locale,
 	)
 
 	if err != nil {
@@ -145,21 +195,22 @@ func (um *UserModel) Get(id uuid.UUID) (*User, error) {
 	return &user, nil
 }
 
-func (um *UserModel) GetByEmail(email string, active bool) (*User, error) {
+func (um *UserModel) GetByEmail(ctx context.Context, tenantID uuid.UUID, email string, active bool) (*User, error) {
 	query := `
-	SELECT u.*, p.*
+	SELECT ` + userColumns + `
 	FROM users u
 	JOIN user_profile p ON p.user_id = u.id
-	WHERE u.is_active = $2 AND u.email = $1`
+	WHERE u.is_active = $2 AND u.email = $1 AND u.tenant_id = $3`
 
 	var user User
 	var userProfile UserProfile
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
 	defer cancel()
 
-	err := um.DB.QueryRowContext(ctx, query, email, active).Scan(
+	err := um.DB.QueryRowContext(ctx, query, email, active, tenantID).Scan(
 		&user.ID,
+		&user.TenantID,
 		&user.Email,
 		&user.Password.hash,
 		&user.FirstName,
@@ -169,20 +220,18 @@ func (um *UserModel) GetByEmail(email string, active bool) (*User, error) {
 		&user.IsSuperuser,
 		&user.Thumbnail,
 		&user.CreatedAt,
+		&user.ReferralCode,
 		&userProfile.ID,
 		&userProfile.UserID,
 		&userProfile.PhoneNumber,
 		&userProfile.BirthDate,
+		&userProfile.Locale,
 	)
 
 	if err != nil {
 		switch {
 		case errors.Is(err, sql.ErrNoRows):
-			if active {
-				return nil, ErrRecordNotFound
-			} else {
-				return nil, errors.New("an inactive user with the provided email address was not found")
-			}
+			return nil, ErrRecordNotFound
 		default:
 			return nil, err
 		}
@@ -193,14 +242,63 @@ func (um *UserModel) GetByEmail(email string, active bool) (*User, error) {
 	return &user, nil
 }
 
-func (um *UserModel) Update(user *User) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+// GetByReferralCode returns the active user of the tenant owning the referral code.
+func (um *UserModel) GetByReferralCode(ctx context.Context, tenantID uuid.UUID, code string) (*User, error) {
+	query := `
+	SELECT ` + userColumns + `
+	FROM users u
+	JOIN user_profile p ON p.user_id = u.id
+	WHERE u.is_active = true AND u.referral_code = upper($1) AND u.tenant_id = $2`
+
+	var user User
+	var userProfile UserProfile
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	err := um.DB.QueryRowContext(ctx, query, code, tenantID).Scan(
+		&user.ID,
+		&user.TenantID,
+		&user.Email,
+		&user.Password.hash,
+		&user.FirstName,
+		&user.LastName,
+		&user.IsActive,
+		&user.IsStaff,
+		&user.IsSuperuser,
+		&user.Thumbnail,
+		&user.CreatedAt,
+		&user.ReferralCode,
+		&userProfile.ID,
+		&userProfile.UserID,
+		&userProfile.PhoneNumber,
+		&userProfile.BirthDate,
+		&userProfile.Locale,
+	)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	user.Profile = userProfile
+
+	return &user, nil
+}
+
+func (um *UserModel) Update(ctx context.Context, user *User) error {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
 	defer cancel()
 
 	tx, err := um.DB.BeginTx(ctx, nil)
 	if err != nil {
-		return nil
+		return err
 	}
+	defer tx.Rollback()
 
 	query_user := `
 	UPDATE
@@ -210,7 +308,7 @@ func (um *UserModel) Update(user *User) error {
 		last_name = COALESCE($2, last_name),
 		thumbnail = COALESCE($3, thumbnail)
 	WHERE
-		id = $4 AND is_active = true
+		id = $4 AND tenant_id = $5 AND is_active = true
 	RETURNING
 		id,
 		email,
@@ -227,12 +325,12 @@ func (um *UserModel) Update(user *User) error {
 		user.LastName,
 		user.Thumbnail,
 		user.ID,
+		user.TenantID,
 	}
 
-	err = um.DB.QueryRowContext(ctx, query_user, args_user...).Scan(
+	err = tx.QueryRowContext(ctx, query_user, args_user...).Scan(
 		&user.ID,
 		&user.Email,
-		&user.Password.hash,
 		&user.FirstName,
 		&user.LastName,
 		&user.IsActive,
@@ -243,7 +341,12 @@ func (um *UserModel) Update(user *User) error {
 	)
 
 	if err != nil {
-		return err
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
 	}
 
 	query_user_profile := `
@@ -251,18 +354,21 @@ func (um *UserModel) Update(user *User) error {
 		user_profile
 	SET
 		phone_number = NULLIF($1, ''),
-		birth_date = $2::timestamp::date
+		birth_date = $2::timestamp::date,
+		locale = COALESCE(NULLIF($3, ''), locale)
 	WHERE
-		user_id = $3
+		user_id = $4
 	RETURNING
 		id,
 		user_id,
 		phone_number,
-		birth_date`
+		birth_date,
+		locale`
 
 	args_user_profile := []interface{}{
 		user.Profile.PhoneNumber,
 		user.Profile.BirthDate,
+		user.Profile.Locale,
 		user.ID,
 	}
 
@@ -271,6 +377,7 @@ func (um *UserModel) Update(user *User) error {
 		&user.Profile.UserID,
 		&user.Profile.PhoneNumber,
 		&user.Profile.BirthDate,
+		&user.Profile.Locale,
 	)
 
 	if err != nil {
@@ -280,20 +387,142 @@ func (um *UserModel) Update(user *User) error {
 	return tx.Commit()
 }
 
-func (um *UserModel) Activate(userID uuid.UUID) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+// Activate activates the user, returning ErrRecordNotFound when the tenant has no
+// such user.
+func (um *UserModel) Activate(ctx context.Context, tenantID, userID uuid.UUID) error {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	query := `UPDATE users SET is_active = true WHERE id = $1 AND tenant_id = $2`
+
+	result, err := um.DB.ExecContext(ctx, query, userID, tenantID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// SetActive activates or deactivates the user, returning ErrRecordNotFound when
+// the tenant has no such user.
+func (um *UserModel) SetActive(ctx context.Context, tenantID, userID uuid.UUID, active bool) error {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	query := `UPDATE users SET is_active = $1 WHERE id = $2 AND tenant_id = $3`
+
+	result, err := um.DB.ExecContext(ctx, query, active, userID, tenantID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// UpdatePassword stores the password hash previously set with user.Password.Set.
+func (um *UserModel) UpdatePassword(ctx context.Context, user *User) error {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
 	defer cancel()
 
-	query := `UPDATE users SET is_active = true WHERE id = $1`
+	query := `UPDATE users SET password = $1 WHERE id = $2 AND tenant_id = $3`
 
-	_, err := um.DB.ExecContext(ctx, query, userID)
+	result, err := um.DB.ExecContext(ctx, query, user.Password.hash, user.ID, user.TenantID)
 	if err != nil {
 		return err
 	}
 
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
 	return nil
 }
 
+// GetAll returns the users of a tenant, active or not.
+func (um *UserModel) GetAll(ctx context.Context, tenantID uuid.UUID, filters Filters) ([]*User, Metadata, error) {
+	query := fmt.Sprintf(`
+	SELECT count(*) OVER(), `+userColumns+`
+	FROM users u
+	JOIN user_profile p ON p.user_id = u.id
+	WHERE u.tenant_id = $1
+	ORDER BY u.%s %s, u.id ASC
+	LIMIT $2 OFFSET $3`, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	rows, err := um.DB.QueryContext(ctx, query, tenantID, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	defer rows.Close()
+
+	totalRecords := 0
+	users := []*User{}
+
+	for rows.Next() {
+		var user User
+
+		err := rows.Scan(
+			&totalRecords,
+			&user.ID,
+			&user.TenantID,
+			&user.Email,
+			&user.Password.hash,
+			&user.FirstName,
+			&user.LastName,
+			&user.IsActive,
+			&user.IsStaff,
+			&user.IsSuperuser,
+			&user.Thumbnail,
+			&user.CreatedAt,
+			&user.ReferralCode,
+			&user.Profile.ID,
+			&user.Profile.UserID,
+			&user.Profile.PhoneNumber,
+			&user.Profile.BirthDate,
+			&user.Profile.Locale,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		users = append(users, &user)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return users, metadata, nil
+}
+
 // The Set() method calculates the bcrypt hash of a plaintext password, and stores both
 // the hash and the plaintext versions in the struct
 func (p *password) Set(plaintextPassword string) error {
@@ -345,6 +574,8 @@ func ValidateUser(v *validator.Validator, user *User) {
 		ValidatePasswordPlaintext(v, *user.Password.plaintext)
 	}
 
+	v.Check(i18n.Supported(user.Profile.Locale), "locale", "is not supported")
+
 	if user.Password.hash == nil {
 		panic("missing password hash for user")
 	}
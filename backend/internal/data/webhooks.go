@@ -0,0 +1,334 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"crossfitbox.booking.system/internal/validator"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// WebhookEventTypes lists the events endpoints can subscribe to.
+var WebhookEventTypes = []string{
+	EventUserRegistered,
+	EventWorkoutCreated,
+}
+
+// EventWebhookTest is only sent by the "send test event" endpoint.
+const EventWebhookTest = "webhook.test"
+
+type WebhookModel struct {
+	DB *sql.DB
+}
+
+type WebhookEndpoint struct {
+	ID          uuid.UUID `json:"id"`
+	TenantID    uuid.UUID `json:"-"`
+	URL         string    `json:"url"`
+	Secret      string    `json:"-"`
+	EventTypes  []string  `json:"event_types"`
+	Description *string   `json:"description"`
+	IsActive    bool      `json:"is_active"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+type WebhookDelivery struct {
+	ID           uuid.UUID `json:"id"`
+	TenantID     uuid.UUID `json:"-"`
+	EndpointID   uuid.UUID `json:"endpoint_id"`
+	EventID      uuid.UUID `json:"event_id"`
+	EventType    string    `json:"event_type"`
+	Attempt      int       `json:"attempt"`
+	StatusCode   *int      `json:"status_code"`
+	ResponseBody *string   `json:"response_body"`
+	Error        *string   `json:"error"`
+	DurationMS   int       `json:"duration_ms"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+const webhookEndpointColumns = `id, tenant_id, url, secret, event_types, description, is_active, created_at, updated_at`
+
+func (e *WebhookEndpoint) scanArgs() []interface{} {
+	return []interface{}{
+		&e.ID,
+		&e.TenantID,
+		&e.URL,
+		&e.Secret,
+		pq.Array(&e.EventTypes),
+		&e.Description,
+		&e.IsActive,
+		&e.CreatedAt,
+		&e.UpdatedAt,
+	}
+}
+
+func (m WebhookModel) Insert(ctx context.Context, endpoint *WebhookEndpoint) error {
+	query := `
+		INSERT INTO webhook_endpoints (tenant_id, url, secret, event_types, description)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, is_active, created_at, updated_at`
+
+	args := []interface{}{
+		endpoint.TenantID,
+		endpoint.URL,
+		endpoint.Secret,
+		pq.Array(endpoint.EventTypes),
+		endpoint.Description,
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+
+	defer cancel()
+
+	return m.DB.QueryRowContext(ctx, query, args...).
+		Scan(&endpoint.ID, &endpoint.IsActive, &endpoint.CreatedAt, &endpoint.UpdatedAt)
+}
+
+func (m WebhookModel) Get(ctx context.Context, tenantID, id uuid.UUID) (*WebhookEndpoint, error) {
+	query := `
+	SELECT ` + webhookEndpointColumns + `
+	FROM webhook_endpoints
+	WHERE id = $1 AND tenant_id = $2`
+
+	var endpoint WebhookEndpoint
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, id, tenantID).Scan(endpoint.scanArgs()...)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &endpoint, nil
+}
+
+// GetAll returns the endpoints of a tenant. With a non-empty eventType only the
+// active endpoints subscribed to it are returned.
+func (m WebhookModel) GetAll(ctx context.Context, tenantID uuid.UUID, eventType string) ([]*WebhookEndpoint, error) {
+	query := `
+	SELECT ` + webhookEndpointColumns + `
+	FROM webhook_endpoints
+	WHERE tenant_id = $1
+	AND ($2 = '' OR (is_active = true AND event_types @> ARRAY[$2]))
+	ORDER BY created_at, id`
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, tenantID, eventType)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	endpoints := []*WebhookEndpoint{}
+
+	for rows.Next() {
+		var endpoint WebhookEndpoint
+
+		err := rows.Scan(endpoint.scanArgs()...)
+		if err != nil {
+			return nil, err
+		}
+
+		endpoints = append(endpoints, &endpoint)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return endpoints, nil
+}
+
+func (m WebhookModel) Update(ctx context.Context, endpoint *WebhookEndpoint) error {
+	query := `
+		UPDATE webhook_endpoints
+		SET url = $1, event_types = $2, description = $3, is_active = $4, updated_at = NOW()
+		WHERE id = $5 AND tenant_id = $6
+		RETURNING updated_at`
+
+	args := []interface{}{
+		endpoint.URL,
+		pq.Array(endpoint.EventTypes),
+		endpoint.Description,
+		endpoint.IsActive,
+		endpoint.ID,
+		endpoint.TenantID,
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&endpoint.UpdatedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+func (m WebhookModel) Delete(ctx context.Context, tenantID, id uuid.UUID) error {
+	query := `DELETE FROM webhook_endpoints WHERE id = $1 AND tenant_id = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id, tenantID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+func (m WebhookModel) InsertDelivery(ctx context.Context, delivery *WebhookDelivery) error {
+	query := `
+		INSERT INTO webhook_deliveries (tenant_id, endpoint_id, event_id, event_type, attempt, status_code, response_body, error, duration_ms)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id, created_at`
+
+	args := []interface{}{
+		delivery.TenantID,
+		delivery.EndpointID,
+		delivery.EventID,
+		delivery.EventType,
+		delivery.Attempt,
+		delivery.StatusCode,
+		delivery.ResponseBody,
+		delivery.Error,
+		delivery.DurationMS,
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+
+	defer cancel()
+
+	return m.DB.QueryRowContext(ctx, query, args...).Scan(&delivery.ID, &delivery.CreatedAt)
+}
+
+// DeleteDeliveries deletes up to limit delivery logs of every tenant recorded
+// before the given time and returns the number of logs deleted.
+func (m WebhookModel) DeleteDeliveries(ctx context.Context, before time.Time, limit int) (int64, error) {
+	query := `
+		DELETE FROM webhook_deliveries
+		WHERE id IN (
+			SELECT id
+			FROM webhook_deliveries
+			WHERE created_at < $1
+			LIMIT $2
+		)`
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, before, limit)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+func (m WebhookModel) GetAllDeliveries(ctx context.Context, tenantID, endpointID uuid.UUID, filters Filters) ([]*WebhookDelivery, Metadata, error) {
+	query := fmt.Sprintf(`
+	SELECT count(*) OVER(), id, tenant_id, endpoint_id, event_id, event_type, attempt, status_code, response_body, error, duration_ms, created_at
+	FROM webhook_deliveries
+	WHERE tenant_id = $1 AND endpoint_id = $2
+	ORDER BY %s %s, id ASC
+	LIMIT $3 OFFSET $4`, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, tenantID, endpointID, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	defer rows.Close()
+
+	totalRecords := 0
+	deliveries := []*WebhookDelivery{}
+
+	for rows.Next() {
+		var delivery WebhookDelivery
+
+		err := rows.Scan(
+			&totalRecords,
+			&delivery.ID,
+			&delivery.TenantID,
+			&delivery.EndpointID,
+			&delivery.EventID,
+			&delivery.EventType,
+			&delivery.Attempt,
+			&delivery.StatusCode,
+			&delivery.ResponseBody,
+			&delivery.Error,
+			&delivery.DurationMS,
+			&delivery.CreatedAt,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		deliveries = append(deliveries, &delivery)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return deliveries, metadata, nil
+}
+
+func ValidateWebhookEndpoint(v *validator.Validator, endpoint *WebhookEndpoint) {
+	v.Check(endpoint.URL != "", "url", "must be provided")
+
+	u, err := url.Parse(endpoint.URL)
+	v.Check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "", "url", "must be an absolute http or https URL")
+
+	v.Check(len(endpoint.EventTypes) >= 1, "event_types", "must contain at least 1 event type")
+	v.Check(validator.Unique(endpoint.EventTypes), "event_types", "must not contain duplicate records")
+
+	for _, eventType := range endpoint.EventTypes {
+		v.Check(validator.In(eventType, WebhookEventTypes...), "event_types", "contains an unknown event type")
+	}
+
+	if endpoint.Description != nil {
+		v.Check(len(*endpoint.Description) <= 500, "description", "must not be more than 500 bytes long")
+	}
+}
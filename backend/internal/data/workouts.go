@@ -18,6 +18,7 @@ type WorkoutModel struct {
 
 type Workout struct {
 	ID          uuid.UUID `json:"id"`
+	TenantID    uuid.UUID `json:"-"`
 	Name        string    `json:"name"`
 	Mode        string    `json:"mode"`
 	TimeCap     TimeCap   `json:"time_cap,omitempty"`
@@ -26,60 +27,195 @@ type Workout struct {
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
 	TrainerTips []string  `json:"trainer_tips,omitempty"`
+	IsBenchmark bool      `json:"is_benchmark"`
+	RequestID   string    `json:"-"`
 }
 
-func (w WorkoutModel) Insert(workout *Workout) error {
+func (w WorkoutModel) Insert(ctx context.Context, workout *Workout) error {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+
+	defer cancel()
+
+	tx, err := w.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = insertWorkout(ctx, tx, workout)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// insertWorkout inserts the workout and its outbox event within tx.
+func insertWorkout(ctx context.Context, tx *sql.Tx, workout *Workout) error {
 	query := `
-		INSERT INTO workouts (name, mode, time_cap, equipment, exercises, trainer_tips)
-		VALUES ($1, $2, $3, $4, $5, $6)
+		INSERT INTO workouts (tenant_id, name, mode, time_cap, equipment, exercises, trainer_tips, is_benchmark)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
 		RETURNING id, updated_at, created_at`
 
 	args := []interface{}{
+		workout.TenantID,
 		workout.Name,
 		workout.Mode,
 		workout.TimeCap,
 		pq.Array(workout.Equipment),
 		pq.Array(workout.Exercises),
 		pq.Array(workout.TrainerTips),
+		workout.IsBenchmark,
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-
-	defer cancel()
-
-	err := w.DB.QueryRowContext(ctx, query, args...).
+	err := tx.QueryRowContext(ctx, query, args...).
 		Scan(&workout.ID, &workout.UpdatedAt, &workout.CreatedAt)
 	if err != nil {
 		switch {
-		case err.Error() == `pq: duplicate key value violates unique constraint "workouts_name_key"`:
+		case err.Error() == `pq: duplicate key value violates unique constraint "workouts_tenant_id_name_key"`:
 			return ErrDuplicateName
 		default:
 			return err
 		}
 	}
-	return nil
+
+	return insertOutboxEvent(ctx, tx, workout.TenantID, EventWorkoutCreated, workout.ID, workout.RequestID, workout)
 }
 
-func (w WorkoutModel) Get(id uuid.UUID) (*Workout, error) {
+// Import inserts the workouts in a single transaction, skipping those whose name
+// is already taken in the tenant, and returns the indexes of the skipped ones.
+// Nothing is committed when dryRun is set, or when atomic is set and a workout
+// was skipped.
+func (w WorkoutModel) Import(ctx context.Context, workouts []*Workout, atomic, dryRun bool) ([]int, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+
+	defer cancel()
+
+	tx, err := w.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	duplicates := []int{}
+
+	for i, workout := range workouts {
+		// A failed statement aborts the whole transaction, the savepoint lets
+		// the import carry on past a duplicate name.
+		_, err = tx.ExecContext(ctx, `SAVEPOINT workout`)
+		if err != nil {
+			return nil, err
+		}
+
+		err = insertWorkout(ctx, tx, workout)
+		if err != nil {
+			if !errors.Is(err, ErrDuplicateName) {
+				return nil, err
+			}
+
+			_, err = tx.ExecContext(ctx, `ROLLBACK TO SAVEPOINT workout`)
+			if err != nil {
+				return nil, err
+			}
+
+			duplicates = append(duplicates, i)
+			continue
+		}
+
+		_, err = tx.ExecContext(ctx, `RELEASE SAVEPOINT workout`)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if dryRun || (atomic && len(duplicates) > 0) {
+		return duplicates, nil
+	}
+
+	return duplicates, tx.Commit()
+}
+
+// Upsert inserts the workout or, when the tenant already has a benchmark workout
+// with the same name, overwrites it. It reports whether the workout was inserted.
+// ErrDuplicateName is returned when the name is taken by a workout of the box's
+// own, which is left untouched.
+func (w WorkoutModel) Upsert(ctx context.Context, workout *Workout) (bool, error) {
 	query := `
-	SELECT id, name, mode, time_cap, equipment, exercises, trainer_tips, created_at, updated_at
+		INSERT INTO workouts (tenant_id, name, mode, time_cap, equipment, exercises, trainer_tips, is_benchmark)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT ON CONSTRAINT workouts_tenant_id_name_key DO UPDATE
+		SET mode = EXCLUDED.mode, time_cap = EXCLUDED.time_cap, equipment = EXCLUDED.equipment,
+			exercises = EXCLUDED.exercises, trainer_tips = EXCLUDED.trainer_tips,
+			is_benchmark = EXCLUDED.is_benchmark, updated_at = NOW()
+		WHERE workouts.is_benchmark
+		RETURNING id, updated_at, created_at, xmax = 0`
+
+	args := []interface{}{
+		workout.TenantID,
+		workout.Name,
+		workout.Mode,
+		workout.TimeCap,
+		pq.Array(workout.Equipment),
+		pq.Array(workout.Exercises),
+		pq.Array(workout.TrainerTips),
+		workout.IsBenchmark,
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+
+	defer cancel()
+
+	tx, err := w.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var inserted bool
+
+	err = tx.QueryRowContext(ctx, query, args...).
+		Scan(&workout.ID, &workout.UpdatedAt, &workout.CreatedAt, &inserted)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return false, ErrDuplicateName
+		default:
+			return false, err
+		}
+	}
+
+	if inserted {
+		err = insertOutboxEvent(ctx, tx, workout.TenantID, EventWorkoutCreated, workout.ID, workout.RequestID, workout)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	return inserted, tx.Commit()
+}
+
+func (w WorkoutModel) Get(ctx context.Context, tenantID, id uuid.UUID) (*Workout, error) {
+	query := `
+	SELECT id, tenant_id, name, mode, time_cap, equipment, exercises, trainer_tips, is_benchmark, created_at, updated_at
 	FROM workouts
-	WHERE id = $1`
+	WHERE id = $1 AND tenant_id = $2`
 
 	var workout Workout
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
 
 	defer cancel()
 
-	err := w.DB.QueryRowContext(ctx, query, id).Scan(
+	err := w.DB.QueryRowContext(ctx, query, id, tenantID).Scan(
 		&workout.ID,
+		&workout.TenantID,
 		&workout.Name,
 		&workout.Mode,
 		&workout.TimeCap,
 		pq.Array(&workout.Equipment),
 		pq.Array(&workout.Exercises),
 		pq.Array(&workout.TrainerTips),
+		&workout.IsBenchmark,
 		&workout.CreatedAt,
 		&workout.UpdatedAt,
 	)
@@ -96,11 +232,11 @@ func (w WorkoutModel) Get(id uuid.UUID) (*Workout, error) {
 	return &workout, nil
 }
 
-func (w WorkoutModel) Update(workout *Workout) error {
+func (w WorkoutModel) Update(ctx context.Context, workout *Workout) error {
 	query := `
 		UPDATE workouts
 		SET name = $1, mode = $2, time_cap = $3, equipment = $4, exercises = $5, trainer_tips = $6, updated_at = NOW()
-		WHERE id = $7
+		WHERE id = $7 AND tenant_id = $8
 		RETURNING id, updated_at`
 	args := []interface{}{
 		workout.Name,
@@ -110,16 +246,19 @@ func (w WorkoutModel) Update(workout *Workout) error {
 		pq.Array(workout.Exercises),
 		pq.Array(workout.TrainerTips),
 		workout.ID,
+		workout.TenantID,
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
 
 	defer cancel()
 
 	err := w.DB.QueryRowContext(ctx, query, args...).Scan(&workout.ID, &workout.UpdatedAt)
 	if err != nil {
 		switch {
-		case err.Error() == `pq: duplicate key value violates unique constraint "workouts_name_key"`:
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		case err.Error() == `pq: duplicate key value violates unique constraint "workouts_tenant_id_name_key"`:
 			return ErrDuplicateName
 		default:
 			return err
@@ -128,14 +267,14 @@ func (w WorkoutModel) Update(workout *Workout) error {
 	return nil
 }
 
-func (w WorkoutModel) Delete(id uuid.UUID) error {
-	query := `DELETE FROM workouts WHERE id = $1`
+func (w WorkoutModel) Delete(ctx context.Context, tenantID, id uuid.UUID) error {
+	query := `DELETE FROM workouts WHERE id = $1 AND tenant_id = $2`
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
 
 	defer cancel()
 
-	result, err := w.DB.ExecContext(ctx, query, id)
+	result, err := w.DB.ExecContext(ctx, query, id, tenantID)
 	if err != nil {
 		return err
 	}
@@ -151,21 +290,22 @@ func (w WorkoutModel) Delete(id uuid.UUID) error {
 	return nil
 }
 
-func (w WorkoutModel) GetAll(name, mode string, equipment []string, filters Filters) ([]*Workout, Metadata, error) {
+func (w WorkoutModel) GetAll(ctx context.Context, tenantID uuid.UUID, name, mode string, equipment []string, filters Filters) ([]*Workout, Metadata, error) {
 	query := fmt.Sprintf(`
-	SELECT count(*) OVER(), id, name, mode, time_cap, equipment, exercises, trainer_tips, created_at, updated_at
+	SELECT count(*) OVER(), id, tenant_id, name, mode, time_cap, equipment, exercises, trainer_tips, is_benchmark, created_at, updated_at
 	FROM workouts
-	WHERE (to_tsvector('simple', name) @@ plainto_tsquery('simple', $1) OR $1 = '')
-	AND (LOWER(mode) = LOWER($2) OR $2 = '')
-	AND (equipment @> $3 OR $3 = '{}')
+	WHERE tenant_id = $1
+	AND (to_tsvector('simple', name) @@ plainto_tsquery('simple', $2) OR $2 = '')
+	AND (LOWER(mode) = LOWER($3) OR $3 = '')
+	AND (equipment @> $4 OR $4 = '{}')
 	ORDER BY %s %s, id ASC
-	LIMIT $4 OFFSET $5`, filters.sortColumn(), filters.sortDirection())
+	LIMIT $5 OFFSET $6`, filters.sortColumn(), filters.sortDirection())
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
 
 	defer cancel()
 
-	args := []interface{}{name, mode, pq.Array(equipment), filters.limit(), filters.offset()}
+	args := []interface{}{tenantID, name, mode, pq.Array(equipment), filters.limit(), filters.offset()}
 
 	rows, err := w.DB.QueryContext(ctx, query, args...)
 	if err != nil {
@@ -183,12 +323,14 @@ func (w WorkoutModel) GetAll(name, mode string, equipment []string, filters Filt
 		err := rows.Scan(
 			&totalRecords,
 			&workout.ID,
+			&workout.TenantID,
 			&workout.Name,
 			&workout.Mode,
 			&workout.TimeCap,
 			pq.Array(&workout.Equipment),
 			pq.Array(&workout.Exercises),
 			pq.Array(&workout.TrainerTips),
+			&workout.IsBenchmark,
 			&workout.CreatedAt,
 			&workout.UpdatedAt,
 		)
@@ -0,0 +1,135 @@
+// Package env holds the configuration shared by the commands under cmd/: loading
+// the .env file, the database, Redis and tenant settings, and opening the
+// connections they describe.
+package env
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/XSAM/otelsql"
+	"github.com/joho/godotenv"
+	"github.com/redis/go-redis/extra/redisotel/v9"
+	"github.com/redis/go-redis/v9"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+)
+
+// Load reads the .env file of the working directory into the environment.
+func Load() error {
+	return godotenv.Load(".env")
+}
+
+type DB struct {
+	DSN          string
+	MaxOpenConns int
+	MaxIdleConns int
+	MaxIdleTime  string
+}
+
+// RegisterFlags defines the database flags on fs, using the environment for their
+// default values.
+func (db *DB) RegisterFlags(fs *flag.FlagSet) error {
+	maxOpenConns, err := intVar("DB_MAX_OPEN_CONNS", 25)
+	if err != nil {
+		return err
+	}
+
+	maxIdleConns, err := intVar("DB_MAX_IDLE_CONNS", 25)
+	if err != nil {
+		return err
+	}
+
+	fs.StringVar(&db.DSN, "db-dsn", os.Getenv("CFBOX_DB_DSN"), "PostgreSQL DSN")
+	fs.IntVar(&db.MaxOpenConns, "db-max-open-conns", maxOpenConns, "PostgreSQL max open connections")
+	fs.IntVar(&db.MaxIdleConns, "db-max-idle-conns", maxIdleConns, "PostgreSQL max idle connections")
+	fs.StringVar(&db.MaxIdleTime, "db-max-idle-time", os.Getenv("DB_MAX_IDLE_TIME"), "PostgreSQL max connection idle time")
+
+	return nil
+}
+
+// intVar parses the integer environment variable key, returning defaultValue when
+// it is not set.
+func intVar(key string, defaultValue int) (int, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue, nil
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", key, err)
+	}
+
+	return n, nil
+}
+
+// DefaultTenant returns the slug of the tenant used when none is given, which is
+// TENANT_DEFAULT or "default".
+func DefaultTenant() string {
+	if slug := os.Getenv("TENANT_DEFAULT"); slug != "" {
+		return slug
+	}
+
+	return "default"
+}
+
+// OpenDB opens the connection pool and checks that the database is reachable.
+// Queries are traced through the global OpenTelemetry tracer provider.
+func OpenDB(cfg DB) (*sql.DB, error) {
+	db, err := otelsql.Open("postgres", cfg.DSN,
+		otelsql.WithAttributes(semconv.DBSystemPostgreSQL),
+		otelsql.WithSpanOptions(otelsql.SpanOptions{
+			OmitConnResetSession: true,
+			OmitRows:             true,
+		}),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	duration, err := time.ParseDuration(cfg.MaxIdleTime)
+	if err != nil {
+		return nil, err
+	}
+	db.SetConnMaxIdleTime(duration)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err = db.PingContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// OpenRedis connects to the Redis server at url. Commands are traced through the
+// global OpenTelemetry tracer provider.
+func OpenRedis(url string) (*redis.Client, error) {
+	opt, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, err
+	}
+
+	client := redis.NewClient(opt)
+
+	err = redisotel.InstrumentTracing(client)
+	if err != nil {
+		return nil, err
+	}
+
+	err = client.Ping(context.Background()).Err()
+	if err != nil {
+		return nil, err
+	}
+
+	return client, nil
+}
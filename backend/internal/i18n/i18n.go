@@ -0,0 +1,106 @@
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DefaultLocale is the language messages are written in. Its catalog maps every
+// translatable message to itself and is the list the other catalogs must cover.
+const DefaultLocale = "en"
+
+//go:embed "locales"
+var localeFS embed.FS
+
+// catalogs maps a locale to its translations, keyed by the English message.
+var catalogs = map[string]map[string]string{}
+
+func init() {
+	entries, err := localeFS.ReadDir("locales")
+	if err != nil {
+		panic(err)
+	}
+
+	for _, entry := range entries {
+		js, err := localeFS.ReadFile(path.Join("locales", entry.Name()))
+		if err != nil {
+			panic(err)
+		}
+
+		catalog := make(map[string]string)
+
+		err = json.Unmarshal(js, &catalog)
+		if err != nil {
+			panic("invalid message catalog " + entry.Name() + ": " + err.Error())
+		}
+
+		catalogs[strings.TrimSuffix(entry.Name(), ".json")] = catalog
+	}
+}
+
+// Supported returns true if messages can be served in locale.
+func Supported(locale string) bool {
+	if locale == DefaultLocale {
+		return true
+	}
+
+	_, ok := catalogs[locale]
+	return ok
+}
+
+// Translate returns message in locale, falling back to the English message when
+// the locale or the message is missing from the catalogs.
+func Translate(locale, message string) string {
+	if translated, ok := catalogs[locale][message]; ok {
+		return translated
+	}
+
+	return message
+}
+
+// Match picks the best supported locale for an Accept-Language header value,
+// e.g. "pl-PL,pl;q=0.9,en;q=0.8". DefaultLocale is returned when nothing matches.
+func Match(acceptLanguage string) string {
+	type tag struct {
+		locale string
+		q      float64
+	}
+
+	var tags []tag
+
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		locale, params, _ := strings.Cut(part, ";")
+		locale = strings.TrimSpace(locale)
+		if locale == "" {
+			continue
+		}
+
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+
+		base, _, _ := strings.Cut(strings.ToLower(locale), "-")
+		tags = append(tags, tag{locale: base, q: q})
+	}
+
+	sort.SliceStable(tags, func(i, j int) bool {
+		return tags[i].q > tags[j].q
+	})
+
+	for _, t := range tags {
+		if t.q > 0 && Supported(t.locale) {
+			return t.locale
+		}
+	}
+
+	return DefaultLocale
+}
@@ -0,0 +1,71 @@
+package i18n
+
+import "testing"
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		acceptLanguage string
+		want           string
+	}{
+		{"", "en"},
+		{"pl", "pl"},
+		{"pl-PL", "pl"},
+		{"PL-pl", "pl"},
+		{"en", "en"},
+		{"de", "en"},
+		{"de-DE,pl;q=0.5", "pl"},
+		{"pl-PL,pl;q=0.9,en;q=0.8", "pl"},
+		{"en;q=0.8,pl;q=0.9", "pl"},
+		{"pl;q=0.8,en", "en"},
+		{"pl;q=0", "en"},
+		{"pl;q=abc,de", "en"},
+		{" pl ; q=0.7 , en ; q=0.6", "pl"},
+		{"*", "en"},
+	}
+
+	for _, tt := range tests {
+		if got := Match(tt.acceptLanguage); got != tt.want {
+			t.Errorf("Match(%q) = %q; want %q", tt.acceptLanguage, got, tt.want)
+		}
+	}
+}
+
+func TestTranslate(t *testing.T) {
+	if got := Translate("pl", "must be provided"); got != "jest wymagane" {
+		t.Errorf("Translate(pl) = %q", got)
+	}
+
+	if got := Translate("pl", "no such message"); got != "no such message" {
+		t.Errorf("missing message not returned as is: %q", got)
+	}
+
+	if got := Translate("de", "must be provided"); got != "must be provided" {
+		t.Errorf("unsupported locale not returned in English: %q", got)
+	}
+}
+
+func TestCatalogsComplete(t *testing.T) {
+	messages, ok := catalogs[DefaultLocale]
+	if !ok {
+		t.Fatalf("no %s catalog", DefaultLocale)
+	}
+
+	for message, translated := range messages {
+		if translated != message {
+			t.Errorf("%s: %q is translated to %q", DefaultLocale, message, translated)
+		}
+	}
+
+	for locale, catalog := range catalogs {
+		for message := range messages {
+			if _, ok := catalog[message]; !ok {
+				t.Errorf("%s: missing %q", locale, message)
+			}
+		}
+		for message := range catalog {
+			if _, ok := messages[message]; !ok {
+				t.Errorf("%s: %q is not in the %s catalog", locale, message, DefaultLocale)
+			}
+		}
+	}
+}
@@ -0,0 +1,212 @@
+package jsonlog
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"runtime/debug"
+	"time"
+)
+
+// Handler is a slog.Handler writing entries in the format of Logger, so that
+// code logging through log/slog shares the output of the application.
+type Handler struct {
+	core *core
+	goas []groupOrAttrs
+}
+
+// groupOrAttrs is either a group opened by WithGroup or attributes bound by
+// WithAttrs, in the order they were applied.
+type groupOrAttrs struct {
+	group string
+	attrs []slog.Attr
+}
+
+func (h *Handler) Enabled(_ context.Context, level slog.Level) bool {
+	return h.enabled(levelFromSlog(level))
+}
+
+func (h *Handler) enabled(level Level) bool {
+	minLevel := Level(h.core.minLevel.Load())
+	return minLevel < LevelOff && level >= minLevel
+}
+
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return h.withAttrs(attrs)
+}
+
+func (h *Handler) withAttrs(attrs []slog.Attr) *Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+
+	return h.with(groupOrAttrs{attrs: attrs})
+}
+
+func (h *Handler) WithGroup(name string) slog.Handler {
+	return h.withGroup(name)
+}
+
+func (h *Handler) withGroup(name string) *Handler {
+	if name == "" {
+		return h
+	}
+
+	return h.with(groupOrAttrs{group: name})
+}
+
+func (h *Handler) with(goa groupOrAttrs) *Handler {
+	goas := make([]groupOrAttrs, len(h.goas), len(h.goas)+1)
+	copy(goas, h.goas)
+
+	return &Handler{core: h.core, goas: append(goas, goa)}
+}
+
+func (h *Handler) Handle(_ context.Context, r slog.Record) error {
+	level := levelFromSlog(r.Level)
+
+	properties := map[string]any{}
+	current := properties
+
+	// Groups opened by WithGroup are left out when no attribute ends up in
+	// them, as the slog handlers do.
+	var parents []map[string]any
+	var names []string
+	for _, goa := range h.goas {
+		if goa.group != "" {
+			group := map[string]any{}
+			current[goa.group] = group
+			parents = append(parents, current)
+			names = append(names, goa.group)
+			current = group
+			continue
+		}
+		for _, a := range goa.attrs {
+			addAttr(current, a)
+		}
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		addAttr(current, a)
+		return true
+	})
+	for i := len(parents) - 1; i >= 0; i-- {
+		if len(current) == 0 {
+			delete(parents[i], names[i])
+		}
+		current = parents[i]
+	}
+
+	aux := struct {
+		Level      string         `json:"level"`
+		Time       string         `json:"time,omitempty"`
+		Message    string         `json:"message"`
+		Properties map[string]any `json:"properties,omitempty"`
+		Trace      string         `json:"trace,omitempty"`
+	}{
+		Level:      level.String(),
+		Message:    r.Message,
+		Properties: properties,
+	}
+
+	// A zero time means the caller left it out; the Logger methods always set it.
+	if !r.Time.IsZero() {
+		aux.Time = r.Time.UTC().Format(time.RFC3339)
+	}
+
+	if level >= LevelError && h.core.traces.Load() {
+		aux.Trace = string(debug.Stack())
+	}
+
+	line, err := json.Marshal(aux)
+	if err != nil {
+		line = []byte(LevelError.String() + ": unable to marshal log message: " + err.Error())
+	}
+
+	h.core.mu.Lock()
+	defer h.core.mu.Unlock()
+
+	_, err = h.core.out.Write(append(line, '\n'))
+	return err
+}
+
+func addAttr(m map[string]any, a slog.Attr) {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+
+	if a.Value.Kind() == slog.KindGroup {
+		attrs := a.Value.Group()
+		if len(attrs) == 0 {
+			return
+		}
+
+		group := m
+		if a.Key != "" {
+			group = map[string]any{}
+			m[a.Key] = group
+		}
+		for _, ga := range attrs {
+			addAttr(group, ga)
+		}
+		if len(group) == 0 && a.Key != "" {
+			delete(m, a.Key)
+		}
+		return
+	}
+
+	m[a.Key] = attrValue(a.Value)
+}
+
+func attrValue(v slog.Value) any {
+	switch v.Kind() {
+	case slog.KindTime:
+		return v.Time().UTC().Format(time.RFC3339)
+	case slog.KindDuration:
+		return v.Duration().String()
+	case slog.KindAny:
+		switch x := v.Any().(type) {
+		case json.Marshaler:
+			return x
+		case error:
+			return x.Error()
+		default:
+			return x
+		}
+	default:
+		return v.Any()
+	}
+}
+
+// slogLevelFatal is the slog level entries of LevelFatal are recorded with.
+const slogLevelFatal = slog.LevelError + 4
+
+func (l Level) slogLevel() slog.Level {
+	switch l {
+	case LevelDebug:
+		return slog.LevelDebug
+	case LevelInfo:
+		return slog.LevelInfo
+	case LevelWarn:
+		return slog.LevelWarn
+	case LevelError:
+		return slog.LevelError
+	default:
+		return slogLevelFatal
+	}
+}
+
+func levelFromSlog(level slog.Level) Level {
+	switch {
+	case level < slog.LevelInfo:
+		return LevelDebug
+	case level < slog.LevelWarn:
+		return LevelInfo
+	case level < slog.LevelError:
+		return LevelWarn
+	case level < slogLevelFatal:
+		return LevelError
+	default:
+		return LevelFatal
+	}
+}
@@ -0,0 +1,130 @@
+package jsonlog
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"testing/slogtest"
+)
+
+// entries decodes the JSON lines written to buf.
+func entries(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+
+	var ms []map[string]any
+	for _, line := range strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n") {
+		if line == "" {
+			continue
+		}
+
+		var m map[string]any
+		if err := json.Unmarshal([]byte(line), &m); err != nil {
+			t.Fatalf("invalid log line %q: %v", line, err)
+		}
+		ms = append(ms, m)
+	}
+
+	return ms
+}
+
+func TestSlogHandler(t *testing.T) {
+	var buf bytes.Buffer
+	h := New(&buf, LevelDebug).Handler()
+
+	// slogtest expects the built-in keys at the top level, next to the
+	// attributes, which the logger writes under "properties".
+	results := func() []map[string]any {
+		var ms []map[string]any
+		for _, e := range entries(t, &buf) {
+			m := map[string]any{slog.LevelKey: e["level"], slog.MessageKey: e["message"]}
+			if time, ok := e["time"]; ok {
+				m[slog.TimeKey] = time
+			}
+			if properties, ok := e["properties"].(map[string]any); ok {
+				for key, value := range properties {
+					m[key] = value
+				}
+			}
+			ms = append(ms, m)
+		}
+		return ms
+	}
+
+	if err := slogtest.TestHandler(h, results); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestHandlerOmitsEmptyGroups(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&buf, LevelDebug)
+
+	logger.WithGroup("request").Info("no attributes")
+	logger.WithGroup("request").WithGroup("user").Info("nested", "id", 1)
+	logger.Info("empty group value", slog.Group("request"), slog.Group("user", slog.Group("tenant")))
+
+	got := entries(t, &buf)
+	if len(got) != 3 {
+		t.Fatalf("got %d entries, want 3", len(got))
+	}
+
+	if properties, ok := got[0]["properties"]; ok {
+		t.Errorf("properties = %v, want none", properties)
+	}
+
+	want := map[string]any{"request": map[string]any{"user": map[string]any{"id": float64(1)}}}
+	if properties, _ := json.Marshal(got[1]["properties"]); string(properties) != mustMarshal(t, want) {
+		t.Errorf("properties = %s, want %s", properties, mustMarshal(t, want))
+	}
+
+	if properties, ok := got[2]["properties"]; ok {
+		t.Errorf("properties = %v, want none", properties)
+	}
+}
+
+func TestLoggerEntries(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&buf, LevelInfo)
+
+	logger.PrintDebug("hidden", nil)
+	logger.PrintInfo("starting server", map[string]string{"addr": ":4000"})
+	logger.PrintError(errors.New("boom"), nil)
+
+	logger.SetLevel(LevelOff)
+	logger.PrintError(errors.New("hidden"), nil)
+
+	got := entries(t, &buf)
+	if len(got) != 2 {
+		t.Fatalf("got %d entries, want 2", len(got))
+	}
+
+	if got[0]["level"] != "INFO" || got[0]["message"] != "starting server" {
+		t.Errorf("entry = %v", got[0])
+	}
+	if properties, _ := got[0]["properties"].(map[string]any); properties["addr"] != ":4000" {
+		t.Errorf("properties = %v", got[0]["properties"])
+	}
+	if _, ok := got[0]["time"]; !ok {
+		t.Error("entry has no time")
+	}
+
+	if got[1]["level"] != "ERROR" || got[1]["message"] != "boom" {
+		t.Errorf("entry = %v", got[1])
+	}
+	if _, ok := got[1]["trace"]; ok {
+		t.Error("stack trace written while disabled")
+	}
+}
+
+func mustMarshal(t *testing.T, v any) string {
+	t.Helper()
+
+	js, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(js)
+}
@@ -1,11 +1,14 @@
 package jsonlog
 
 import (
-	"encoding/json"
+	"context"
+	"fmt"
 	"io"
+	"log/slog"
 	"os"
-	"runtime/debug"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -13,92 +16,211 @@ import (
 type Level int8
 
 const (
-	LevelInfo  Level = iota // value 0
-	LevelDebug              // value 1
-	LevelError              // value 2
-	LevelFatal              // value 3
-	LevelOff                // value 4
+	LevelDebug Level = iota // value 0
+	LevelInfo               // value 1
+	LevelWarn               // value 2
+	LevelError              // value 3
+	LevelFatal              // value 4
+	LevelOff                // value 5
 )
 
 // Return a human-friendly string for the severity level
 func (l Level) String() string {
 	switch l {
+	case LevelDebug:
+		return "DEBUG"
 	case LevelInfo:
 		return "INFO"
+	case LevelWarn:
+		return "WARN"
 	case LevelError:
 		return "ERROR"
 	case LevelFatal:
 		return "FATAL"
+	case LevelOff:
+		return "OFF"
 	default:
 		return ""
 	}
 }
 
+// ParseLevel returns the level named s, ignoring case.
+func ParseLevel(s string) (Level, error) {
+	for l := LevelDebug; l <= LevelOff; l++ {
+		if strings.EqualFold(s, l.String()) {
+			return l, nil
+		}
+	}
+
+	return 0, fmt.Errorf("unknown log level %q", s)
+}
+
+func (l Level) MarshalText() ([]byte, error) {
+	return []byte(l.String()), nil
+}
+
+func (l *Level) UnmarshalText(text []byte) error {
+	level, err := ParseLevel(string(text))
+	if err != nil {
+		return err
+	}
+
+	*l = level
+	return nil
+}
+
+// Logger writes JSON log entries. Loggers derived with With() and WithGroup() add
+// their bound attributes to every entry and share the output, the minimum level
+// and the stack trace setting of the logger they were derived from.
 type Logger struct {
-	out      io.Writer
-	minLevel Level
-	mu       sync.Mutex
+	handler *Handler
 }
 
 func New(out io.Writer, minLevel Level) *Logger {
-	return &Logger{
-		out:      out,
-		minLevel: minLevel,
-	}
+	c := &core{out: out}
+	c.minLevel.Store(int32(minLevel))
+
+	return &Logger{handler: &Handler{core: c}}
+}
+
+// With returns a logger that adds args to every entry. args are key-value pairs
+// or slog.Attr values, as accepted by slog.Logger.With.
+func (l *Logger) With(args ...any) *Logger {
+	return &Logger{handler: l.handler.withAttrs(argsToAttrs(args))}
+}
+
+// WithGroup returns a logger that nests the attributes of every entry under name.
+func (l *Logger) WithGroup(name string) *Logger {
+	return &Logger{handler: l.handler.withGroup(name)}
+}
+
+// Level returns the minimum level of the entries written.
+func (l *Logger) Level() Level {
+	return Level(l.handler.core.minLevel.Load())
+}
+
+// SetLevel changes the minimum level of the logger and of every logger derived
+// from the same New() call.
+func (l *Logger) SetLevel(level Level) {
+	l.handler.core.minLevel.Store(int32(level))
+}
+
+// SetStackTraces sets whether error and fatal entries include the stack trace of
+// the goroutine that logged them.
+func (l *Logger) SetStackTraces(enabled bool) {
+	l.handler.core.traces.Store(enabled)
+}
+
+// Handler returns the slog.Handler writing in the format of the logger.
+func (l *Logger) Handler() *Handler {
+	return l.handler
+}
+
+// Slog returns a slog.Logger writing through the logger, for libraries and code
+// using log/slog.
+func (l *Logger) Slog() *slog.Logger {
+	return slog.New(l.handler)
+}
+
+func (l *Logger) Debug(message string, args ...any) {
+	l.log(LevelDebug, message, args)
+}
+
+func (l *Logger) Info(message string, args ...any) {
+	l.log(LevelInfo, message, args)
+}
+
+func (l *Logger) Warn(message string, args ...any) {
+	l.log(LevelWarn, message, args)
+}
+
+func (l *Logger) Error(err error, args ...any) {
+	l.log(LevelError, err.Error(), args)
+}
+
+func (l *Logger) Fatal(err error, args ...any) {
+	l.log(LevelFatal, err.Error(), args)
+	os.Exit(1)
 }
 
 func (l *Logger) PrintInfo(message string, properties map[string]string) {
-	l.print(LevelInfo, message, properties)
+	l.log(LevelInfo, message, propertiesToArgs(properties))
 }
 
 func (l *Logger) PrintError(err error, properties map[string]string) {
-	l.print(LevelError, err.Error(), properties)
+	l.log(LevelError, err.Error(), propertiesToArgs(properties))
 }
 
 func (l *Logger) PrintFatal(err error, properties map[string]string) {
-	l.print(LevelFatal, err.Error(), properties)
+	l.log(LevelFatal, err.Error(), propertiesToArgs(properties))
 	os.Exit(1)
 }
 
 func (l *Logger) PrintDebug(message string, properties map[string]string) {
-	l.print(LevelDebug, message, properties)
+	l.log(LevelDebug, message, propertiesToArgs(properties))
 }
 
-func (l *Logger) print(level Level, message string, properties map[string]string) (int, error) {
-	if level < l.minLevel {
-		return 0, nil
+func (l *Logger) log(level Level, message string, args []any) {
+	if !l.handler.enabled(level) {
+		return
 	}
 
-	aux := struct {
-		Level      string            `json:"level"`
-		Time       string            `json:"time"`
-		Message    string            `json:"message"`
-		Properties map[string]string `json:"properties,omitempty"`
-		Trace      string            `json:"trace,omitempty"`
-	}{
-		Level:      level.String(),
-		Time:       time.Now().UTC().Format(time.RFC3339),
-		Message:    message,
-		Properties: properties,
-	}
+	r := slog.NewRecord(time.Now(), level.slogLevel(), message, 0)
+	r.Add(args...)
+
+	l.handler.Handle(context.Background(), r)
+}
 
-	if level >= LevelError {
-		aux.Trace = string(debug.Stack())
+// Write logs message as an error, so that the logger can be used as the output of
+// a log.Logger, e.g. the ErrorLog of an http.Server.
+func (l *Logger) Write(message []byte) (n int, err error) {
+	l.log(LevelError, strings.TrimSuffix(string(message), "\n"), nil)
+	return len(message), nil
+}
+
+type contextKey struct{}
+
+// NewContext returns a copy of ctx carrying the logger.
+func NewContext(ctx context.Context, l *Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, l)
+}
+
+// FromContext returns the logger carried by ctx, or fallback when there is none.
+func FromContext(ctx context.Context, fallback *Logger) *Logger {
+	if l, ok := ctx.Value(contextKey{}).(*Logger); ok {
+		return l
 	}
 
-	var line []byte
+	return fallback
+}
 
-	line, err := json.Marshal(aux)
-	if err != nil {
-		line = []byte(LevelError.String() + ": unable to marshal log message:" + err.Error())
+// propertiesToArgs converts the properties of the Print methods to attributes.
+func propertiesToArgs(properties map[string]string) []any {
+	args := make([]any, 0, len(properties))
+	for key, value := range properties {
+		args = append(args, slog.String(key, value))
 	}
 
-	l.mu.Lock()
-	defer l.mu.Unlock()
+	return args
+}
+
+func argsToAttrs(args []any) []slog.Attr {
+	var r slog.Record
+	r.Add(args...)
+
+	attrs := make([]slog.Attr, 0, r.NumAttrs())
+	r.Attrs(func(a slog.Attr) bool {
+		attrs = append(attrs, a)
+		return true
+	})
 
-	return l.out.Write(append(line, '\n'))
+	return attrs
 }
 
-func (l *Logger) Write(message []byte) (n int, err error) {
-	return l.print(LevelError, string(message), nil)
+// core is the state shared by a logger and the loggers derived from it.
+type core struct {
+	out      io.Writer
+	mu       sync.Mutex
+	minLevel atomic.Int32
+	traces   atomic.Bool
 }
@@ -0,0 +1,71 @@
+package mailer
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// FileTransport writes every message as an .eml file instead of delivering it, so
+// development and tests don't need a mail server. When no directory is configured
+// the messages are written to stdout.
+type FileTransport struct {
+	dir string
+	out io.Writer
+	mu  sync.Mutex
+}
+
+func NewFileTransport(dir string) (*FileTransport, error) {
+	if dir == "" {
+		return &FileTransport{out: os.Stdout}, nil
+	}
+
+	err := os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return nil, err
+	}
+
+	return &FileTransport{dir: dir}, nil
+}
+
+func (t *FileTransport) Send(_ context.Context, msg *Message) error {
+	if t.dir == "" {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+
+		_, err := mimeMessage(msg).WriteTo(t.out)
+		if err != nil {
+			return err
+		}
+
+		_, err = io.WriteString(t.out, "\n")
+		return err
+	}
+
+	suffix := make([]byte, 4)
+	_, err := rand.Read(suffix)
+	if err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("%s-%s.eml", time.Now().UTC().Format("20060102T150405.000000000"), hex.EncodeToString(suffix))
+
+	f, err := os.Create(filepath.Join(t.dir, name))
+	if err != nil {
+		return err
+	}
+
+	_, err = mimeMessage(msg).WriteTo(f)
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
@@ -2,40 +2,85 @@ package mailer
 
 import (
 	"bytes"
+	"context"
 	"embed"
-	"fmt"
 	"html/template"
-	"time"
+	"io/fs"
+	"path"
 
-	models "crossfitbox.booking.system/internal/data"
-
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/awserr"
-	"github.com/aws/aws-sdk-go/aws/session"
-	"github.com/aws/aws-sdk-go/service/ses"
 	"github.com/go-mail/mail/v2"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
 //go:embed "templates"
 var templateFS embed.FS
 
-type Mailer struct {
-	dialer *mail.Dialer
-	sender string
+var tracer = otel.Tracer("crossfitbox.booking.system/internal/mailer")
+
+// Message is a rendered email, ready to be handed over to a Transport.
+type Message struct {
+	From      string
+	To        string
+	Subject   string
+	PlainBody string
+	HTMLBody  string
+}
+
+// Transport delivers rendered messages. Implementations are selected by config.
+type Transport interface {
+	Send(ctx context.Context, msg *Message) error
 }
 
-func New(host string, port int, username, password, sender string) Mailer {
-	dialer := mail.NewDialer(host, port, username, password)
-	dialer.Timeout = 5 * time.Second
+// Pinger is implemented by transports that can check that the service they deliver
+// through is reachable.
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
 
+type Mailer struct {
+	transport Transport
+	sender    string
+}
+
+func New(transport Transport, sender string) Mailer {
 	return Mailer{
-		dialer: dialer,
-		sender: sender,
+		transport: transport,
+		sender:    sender,
 	}
 }
 
-func (m Mailer) Send(recipient, templateFile string, data interface{}) error {
-	tmpl, err := template.New("email").ParseFS(templateFS, "templates/"+templateFile)
+// Send renders the "subject", "plainBody" and "htmlBody" templates of templateFile
+// with data and delivers the result to recipient through the configured transport.
+// The template is looked up in templates/<locale>/ first and falls back to the
+// English template in templates/. Rendering and delivery are traced as one span, a
+// child of the span in ctx.
+func (m Mailer) Send(ctx context.Context, recipient, locale, templateFile string, data interface{}) (err error) {
+	ctx, span := tracer.Start(ctx, "mailer.Send",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("mail.template", templateFile),
+			attribute.String("mail.locale", locale),
+		),
+	)
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}()
+
+	pattern := path.Join("templates", locale, templateFile)
+
+	_, err = fs.Stat(templateFS, pattern)
+	if locale == "" || err != nil {
+		pattern = path.Join("templates", templateFile)
+	}
+
+	tmpl, err := template.New("email").ParseFS(templateFS, pattern)
 	if err != nil {
 		return err
 	}
@@ -49,121 +94,34 @@ func (m Mailer) Send(recipient, templateFile string, data interface{}) error {
 	plainBody := new(bytes.Buffer)
 	err = tmpl.ExecuteTemplate(plainBody, "plainBody", data)
 	if err != nil {
-		return nil
+		return err
 	}
 
 	htmlBody := new(bytes.Buffer)
 	err = tmpl.ExecuteTemplate(htmlBody, "htmlBody", data)
-	if err != nil {
-		return nil
-	}
-
-	msg := mail.NewMessage()
-	msg.SetHeader("To", recipient)
-	msg.SetHeader("From", m.sender)
-	msg.SetHeader("Subject", subject.String())
-	msg.SetBody("text/plain", plainBody.String())
-	msg.AddAlternative("text/html", htmlBody.String())
-
-	err = m.dialer.DialAndSend(msg)
 	if err != nil {
 		return err
 	}
 
-	return nil
-}
-
-const (
-	// The subject line for the email.
-	Subject = "Welcome to CrossBoxFit!"
-
-	// The HTML body for the email.
-	HtmlBodyTemplate = "<p>Hi,</p>" +
-		"<p>Thanks for signing up for a CrossBoxFit account. We're excited to have you on board!</p> <p>For future reference, your user ID number is %s.</p>" +
-		"<p>Thanks,</p>" +
-		"<p>The CrossBoxFit Team</p>"
-
-	//The email body for recipients with non-HTML email clients.
-	TextBodyTemplate = "Hi,\n" +
-
-		"Thanks for signing up for a CrossBoxFit account. We're exited to have you on board!\n" +
-
-		"For future reference, your user ID number is %s.\n" +
-
-		"Thanks,\n" +
-
-		"The CrossBoxFit Team"
-
-	// The character encoding for the email.
-	CharSet = "UTF-8"
-)
-
-func (m Mailer) SendMailAWS(recipient string, data interface{}) error {
-	sess, err := session.NewSessionWithOptions(session.Options{
-		Profile: "cfbox-ses",
-	})
-	if err != nil {
-		return err
+	msg := &Message{
+		From:      m.sender,
+		To:        recipient,
+		Subject:   subject.String(),
+		PlainBody: plainBody.String(),
+		HTMLBody:  htmlBody.String(),
 	}
 
-	HtmlBody := fmt.Sprintf(HtmlBodyTemplate, data.(*models.User).ID)
-	TextBody := fmt.Sprintf(TextBodyTemplate, data.(*models.User).ID)
-
-	// Create an SES session.
-	svc := ses.New(sess, &aws.Config{
-		Region: aws.String("eu-central-1"),
-	})
-
-	// Assemble the email.
-	input := &ses.SendEmailInput{
-		Destination: &ses.Destination{
-			CcAddresses: []*string{},
-			ToAddresses: []*string{
-				aws.String(recipient),
-			},
-		},
-		Message: &ses.Message{
-			Body: &ses.Body{
-				Html: &ses.Content{
-					Charset: aws.String(CharSet),
-					Data:    aws.String(HtmlBody),
-				},
-				Text: &ses.Content{
-					Charset: aws.String(CharSet),
-					Data:    aws.String(TextBody),
-				},
-			},
-			Subject: &ses.Content{
-				Charset: aws.String(CharSet),
-				Data:    aws.String(Subject),
-			},
-		},
-		Source: aws.String(m.sender),
-		// Uncomment to use a configuration set
-		//ConfigurationSetName: aws.String(ConfigurationSet),
-	}
-
-	// Attempt to send the email.
-	result, err := svc.SendEmail(input)
+	return m.transport.Send(ctx, msg)
+}
 
-	// Display error messages if they occur.
-	if err != nil {
-		if aerr, ok := err.(awserr.Error); ok {
-			switch aerr.Code() {
-			case ses.ErrCodeMessageRejected:
-				return aerr
-			case ses.ErrCodeMailFromDomainNotVerifiedException:
-				return aerr
-			case ses.ErrCodeConfigurationSetDoesNotExistException:
-				return aerr
-			default:
-				return aerr
-			}
-		}
-		return err
-	}
+// mimeMessage converts msg to a multipart/alternative go-mail message.
+func mimeMessage(msg *Message) *mail.Message {
+	m := mail.NewMessage()
+	m.SetHeader("To", msg.To)
+	m.SetHeader("From", msg.From)
+	m.SetHeader("Subject", msg.Subject)
+	m.SetBody("text/plain", msg.PlainBody)
+	m.AddAlternative("text/html", msg.HTMLBody)
 
-	fmt.Println("Email Sent to address: " + recipient)
-	fmt.Println(result)
-	return nil
+	return m
 }
@@ -0,0 +1,66 @@
+package mailer
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ses"
+)
+
+// The character encoding for the email.
+const charSet = "UTF-8"
+
+// SESTransport delivers messages through Amazon SES.
+type SESTransport struct {
+	svc *ses.SES
+}
+
+// NewSESTransport creates an SES client for region. Credentials are loaded from
+// the named shared config profile, or from the default AWS credential chain when
+// profile is empty.
+func NewSESTransport(region, profile string) (*SESTransport, error) {
+	sess, err := session.NewSessionWithOptions(session.Options{
+		Profile:           profile,
+		SharedConfigState: session.SharedConfigEnable,
+		Config: aws.Config{
+			Region: aws.String(region),
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &SESTransport{svc: ses.New(sess)}, nil
+}
+
+func (t *SESTransport) Send(ctx context.Context, msg *Message) error {
+	input := &ses.SendEmailInput{
+		Destination: &ses.Destination{
+			ToAddresses: []*string{
+				aws.String(msg.To),
+			},
+		},
+		Message: &ses.Message{
+			Body: &ses.Body{
+				Html: &ses.Content{
+					Charset: aws.String(charSet),
+					Data:    aws.String(msg.HTMLBody),
+				},
+				Text: &ses.Content{
+					Charset: aws.String(charSet),
+					Data:    aws.String(msg.PlainBody),
+				},
+			},
+			Subject: &ses.Content{
+				Charset: aws.String(charSet),
+				Data:    aws.String(msg.Subject),
+			},
+		},
+		Source: aws.String(msg.From),
+	}
+
+	_, err := t.svc.SendEmailWithContext(ctx, input)
+
+	return err
+}
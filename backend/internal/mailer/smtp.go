@@ -0,0 +1,67 @@
+package mailer
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/smtp"
+	"strconv"
+	"time"
+
+	"github.com/go-mail/mail/v2"
+)
+
+// SMTPTransport delivers messages through an SMTP server.
+type SMTPTransport struct {
+	dialer *mail.Dialer
+}
+
+func NewSMTPTransport(host string, port int, username, password string) *SMTPTransport {
+	dialer := mail.NewDialer(host, port, username, password)
+	dialer.Timeout = 5 * time.Second
+
+	return &SMTPTransport{dialer: dialer}
+}
+
+// Send delivers msg unless ctx is already done. go-mail can't cancel a delivery
+// in progress, which is bounded by the timeout of the dialer instead.
+func (t *SMTPTransport) Send(ctx context.Context, msg *Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return t.dialer.DialAndSend(mimeMessage(msg))
+}
+
+// Ping connects to the SMTP server and waits for its greeting, without logging in
+// or sending anything, to check that the server is reachable.
+func (t *SMTPTransport) Ping(ctx context.Context) error {
+	var d net.Dialer
+
+	conn, err := d.DialContext(ctx, "tcp", net.JoinHostPort(t.dialer.Host, strconv.Itoa(t.dialer.Port)))
+	if err != nil {
+		return err
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	if t.dialer.SSL {
+		conn = tls.Client(conn, &tls.Config{ServerName: t.dialer.Host})
+	}
+
+	c, err := smtp.NewClient(conn, t.dialer.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+
+	err = c.Quit()
+	if err != nil {
+		c.Close()
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,29 @@
+// Package openapi embeds the OpenAPI 3 document describing the routes of the API
+// and a Redoc page rendering it.
+package openapi
+
+import (
+	_ "embed"
+)
+
+// Spec is the OpenAPI document. It is maintained by hand, so any change to a
+// route, its parameters or its envelope has to be reflected here.
+//
+//go:embed openapi.json
+var Spec []byte
+
+// DocsPage is an HTML page that renders the document served at
+// /api/v1/openapi.json with Redoc.
+const DocsPage = `<!DOCTYPE html>
+<html>
+<head>
+	<title>CrossfitBox Booking System API</title>
+	<meta charset="utf-8">
+	<meta name="viewport" content="width=device-width, initial-scale=1">
+</head>
+<body>
+	<redoc spec-url="/api/v1/openapi.json"></redoc>
+	<script src="https://cdn.redoc.ly/redoc/latest/bundles/redoc.standalone.js"></script>
+</body>
+</html>
+`
@@ -1,10 +1,10 @@
 package tokens
 
 import (
-	"crypto/rand"
+	"crypto/hmac"
 	"crypto/sha256"
+	"encoding/binary"
 	"fmt"
-	"math/big"
 	"strings"
 
 	"crossfitbox.booking.system/internal/validator"
@@ -15,25 +15,24 @@ type Token struct {
 	Hash   string
 }
 
-func GenerateOTP() (*Token, error) {
-	bigInt, err := rand.Int(rand.Reader, big.NewInt(900000))
-	if err != nil {
-		return nil, err
-	}
-
-	sixDigitNum := bigInt.Int64() + 100000
+// DeriveOTP returns the OTP derived from key and seed. The same seed always gives
+// the same OTP, so work that is retried, e.g. a job, can send the OTP again
+// without ever storing its secret.
+func DeriveOTP(key []byte, seed string) *Token {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(seed))
 
-	sixDigitStr := fmt.Sprintf("%06d", sixDigitNum)
+	n := binary.BigEndian.Uint64(mac.Sum(nil))%900000 + 100000
 
 	token := Token{
-		Secret: sixDigitStr,
+		Secret: fmt.Sprintf("%06d", n),
 	}
 
 	hash := sha256.Sum256([]byte(token.Secret))
 
 	token.Hash = fmt.Sprintf("%x\n", hash)
 
-	return &token, nil
+	return &token
 }
 
 func FormatOTP(s string) string {
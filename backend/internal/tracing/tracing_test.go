@@ -0,0 +1,28 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTraceParent(t *testing.T) {
+	const traceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+	if got := TraceParent(context.Background()); got != "" {
+		t.Errorf("TraceParent() without a span = %q, want empty", got)
+	}
+
+	ctx := ContextWithTraceParent(context.Background(), traceparent)
+	if got := TraceParent(ctx); got != traceparent {
+		t.Errorf("TraceParent() = %q, want %q", got, traceparent)
+	}
+
+	for _, malformed := range []string{"", "00-abc-def-01", "00-00000000000000000000000000000000-00f067aa0ba902b7-01"} {
+		ctx := ContextWithTraceParent(context.Background(), malformed)
+		if trace.SpanContextFromContext(ctx).IsValid() {
+			t.Errorf("ContextWithTraceParent(%q) carries a span", malformed)
+		}
+	}
+}
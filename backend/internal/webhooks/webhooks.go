@@ -0,0 +1,193 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// Headers sent with every delivery. SignatureHeader has the form
+// "t=<unix timestamp>,v1=<hex HMAC-SHA256 of "<timestamp>.<body>">", so receivers
+// can verify the payload and reject replayed deliveries.
+const (
+	SignatureHeader = "X-Webhook-Signature"
+	EventIDHeader   = "X-Webhook-Event-ID"
+	EventTypeHeader = "X-Webhook-Event-Type"
+)
+
+// Responses are truncated to maxResponseBytes before being stored in the delivery log.
+const maxResponseBytes = 4096
+
+// Result describes the response of the receiver.
+type Result struct {
+	StatusCode   int
+	ResponseBody string
+	Duration     time.Duration
+}
+
+// OK returns true if the receiver accepted the delivery with a 2xx status.
+func (r *Result) OK() bool {
+	return r.StatusCode >= 200 && r.StatusCode < 300
+}
+
+type Client struct {
+	http *http.Client
+}
+
+// ErrForbiddenAddress is returned when an endpoint resolves to an address webhooks
+// must not be delivered to.
+var ErrForbiddenAddress = errors.New("webhooks: endpoint resolves to a non-public address")
+
+// New returns a client for delivering webhooks. Receivers are third parties, so the
+// requests don't carry the trace context of the job delivering them.
+//
+// Endpoints are entered by box superusers, so the client must not be usable to
+// reach the internal network: the address is checked after DNS resolution, right
+// before connecting, and redirects are not followed. Proxies from the environment
+// are ignored, as they would bypass the check.
+func New(timeout time.Duration) *Client {
+	return newClient(timeout, AllowedIP)
+}
+
+// newClient returns a client connecting only to the addresses allowed by allow.
+func newClient(timeout time.Duration, allow func(net.IP) bool) *Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+
+			if !allow(net.ParseIP(host)) {
+				return ErrForbiddenAddress
+			}
+
+			return nil
+		},
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &Client{
+		http: &http.Client{
+			Timeout:   timeout,
+			Transport: transport,
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+// sharedNetworks are not covered by net.IP.IsPrivate but still lead to internal
+// addresses: the carrier-grade NAT range, which cloud providers also use for their
+// metadata services, and the NAT64 prefixes, which map to any IPv4 address.
+var sharedNetworks = []*net.IPNet{
+	mustParseCIDR("100.64.0.0/10"),
+	mustParseCIDR("64:ff9b::/96"),
+	mustParseCIDR("64:ff9b:1::/48"),
+}
+
+func mustParseCIDR(s string) *net.IPNet {
+	_, network, err := net.ParseCIDR(s)
+	if err != nil {
+		panic(err)
+	}
+	return network
+}
+
+// AllowedIP reports whether webhooks may be delivered to ip: it must be a public
+// unicast address.
+func AllowedIP(ip net.IP) bool {
+	for _, network := range sharedNetworks {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+
+	switch {
+	case ip == nil,
+		ip.IsLoopback(),
+		ip.IsPrivate(),
+		ip.IsLinkLocalUnicast(),
+		ip.IsLinkLocalMulticast(),
+		ip.IsInterfaceLocalMulticast(),
+		ip.IsMulticast(),
+		ip.IsUnspecified():
+		return false
+	default:
+		return true
+	}
+}
+
+// GenerateSecret returns a random signing secret for a new endpoint.
+func GenerateSecret() (string, error) {
+	b := make([]byte, 32)
+
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	return "whsec_" + hex.EncodeToString(b), nil
+}
+
+// Sign returns the value of SignatureHeader for body.
+func Sign(secret string, timestamp time.Time, body []byte) string {
+	ts := strconv.FormatInt(timestamp.Unix(), 10)
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ts))
+	mac.Write([]byte("."))
+	mac.Write(body)
+
+	return fmt.Sprintf("t=%s,v1=%s", ts, hex.EncodeToString(mac.Sum(nil)))
+}
+
+// Send posts the signed body to url. A non-nil error means the receiver couldn't be
+// reached; receiver errors are reported through the Result status code.
+func (c *Client) Send(ctx context.Context, url, secret, eventID, eventType string, body []byte) (*Result, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "CrossBoxFit-Webhooks/1.0")
+	req.Header.Set(EventIDHeader, eventID)
+	req.Header.Set(EventTypeHeader, eventType)
+	req.Header.Set(SignatureHeader, Sign(secret, time.Now(), body))
+
+	start := time.Now()
+
+	res, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	response, err := io.ReadAll(io.LimitReader(res.Body, maxResponseBytes))
+	if err != nil {
+		return nil, err
+	}
+
+	return &Result{
+		StatusCode:   res.StatusCode,
+		ResponseBody: string(response),
+		Duration:     time.Since(start),
+	}, nil
+}
@@ -0,0 +1,128 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestAllowedIP(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:2800:220:1:248:1893:25c8:1946", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"fe80::1", false},
+		{"fd00::1", false},
+		{"0.0.0.0", false},
+		{"::", false},
+		{"::ffff:127.0.0.1", false},
+		{"224.0.0.1", false},
+		{"100.64.0.1", false},
+		{"100.100.100.200", false},
+		{"100.127.255.255", false},
+		{"100.128.0.1", true},
+		{"64:ff9b::a9fe:a9fe", false},
+		{"64:ff9b::7f00:1", false},
+		{"64:ff9b:1::a00:1", false},
+	}
+
+	for _, tt := range tests {
+		if got := AllowedIP(net.ParseIP(tt.ip)); got != tt.want {
+			t.Errorf("AllowedIP(%s) = %t; want %t", tt.ip, got, tt.want)
+		}
+	}
+}
+
+func TestSendRejectsForbiddenAddress(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("request reached the loopback server")
+	}))
+	defer srv.Close()
+
+	_, err := New(time.Second).Send(context.Background(), srv.URL, "secret", "id", "webhook.test", []byte(`{}`))
+	if !errors.Is(err, ErrForbiddenAddress) {
+		t.Fatalf("got error %v; want %v", err, ErrForbiddenAddress)
+	}
+}
+
+func TestSendDoesNotFollowRedirects(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			t.Errorf("redirect to %s was followed", r.URL.Path)
+		}
+		http.Redirect(w, r, "/internal", http.StatusFound)
+	}))
+	defer srv.Close()
+
+	client := newClient(time.Second, func(net.IP) bool { return true })
+
+	result, err := client.Send(context.Background(), srv.URL, "secret", "id", "webhook.test", []byte(`{}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.StatusCode != http.StatusFound || result.OK() {
+		t.Errorf("got status %d; want %d, not OK", result.StatusCode, http.StatusFound)
+	}
+}
+
+func TestSendDoesNotPropagateTraceContext(t *testing.T) {
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator())
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("traceparent"); got != "" {
+			t.Errorf("receiver got traceparent %q", got)
+		}
+	}))
+	defer srv.Close()
+
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{1},
+		SpanID:     trace.SpanID{1},
+		TraceFlags: trace.FlagsSampled,
+	}))
+
+	client := newClient(time.Second, func(net.IP) bool { return true })
+
+	_, err := client.Send(ctx, srv.URL, "secret", "id", "webhook.test", []byte(`{}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSign(t *testing.T) {
+	ts := time.Unix(1700000000, 0)
+	body := []byte(`{"id":"1"}`)
+
+	// HMAC-SHA256 of `1700000000.{"id":"1"}` keyed with "whsec_test".
+	want := "t=1700000000,v1=11bf4466ea17c3df3fd743af0b435368e16b7a05eb8eced85e8c4670767bdec5"
+
+	if got := Sign("whsec_test", ts, body); got != want {
+		t.Errorf("Sign() = %q; want %q", got, want)
+	}
+
+	if Sign("whsec_other", ts, body) == want {
+		t.Error("signature doesn't depend on the secret")
+	}
+
+	if Sign("whsec_test", ts.Add(time.Second), body) == want {
+		t.Error("signature doesn't depend on the timestamp")
+	}
+}
@@ -0,0 +1,337 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"crossfitbox.booking.system/internal/data"
+	"crossfitbox.booking.system/internal/jsonlog"
+	"crossfitbox.booking.system/internal/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// Jobs running for longer than lockLease are considered abandoned and are
+	// claimed again by the next poll.
+	lockLease = 5 * time.Minute
+	// Each job gets jobTimeout to finish before its context is cancelled.
+	jobTimeout = time.Minute
+
+	baseBackoff = 10 * time.Second
+	maxBackoff  = time.Hour
+
+	// Maximum number of outbox events moved to the job queue per transaction.
+	dispatchBatchSize = 100
+
+	// Rows older than the retention are deleted every pruneInterval, at most
+	// pruneBatchSize per statement so that the tables aren't locked for long.
+	pruneInterval  = time.Hour
+	pruneBatchSize = 1000
+)
+
+var tracer = otel.Tracer("crossfitbox.booking.system/internal/worker")
+
+// Handler processes a single job. Returning an error records a failed attempt and
+// schedules a retry with exponential backoff.
+type Handler func(ctx context.Context, job *data.Job) error
+
+// Pool polls the jobs table and runs due jobs with the handler registered for their
+// kind. It also dispatches outbox events to the job kinds subscribed to them, and
+// prunes the completed jobs, dispatched events and webhook delivery logs older than
+// the retention.
+type Pool struct {
+	jobs          data.JobModel
+	outbox        data.OutboxModel
+	webhooks      data.WebhookModel
+	logger        *jsonlog.Logger
+	handlers      map[string]Handler
+	subscriptions map[string][]string
+	size          int
+	pollInterval  time.Duration
+	retention     time.Duration
+	cancel        context.CancelFunc
+	wg            sync.WaitGroup
+}
+
+// New creates a pool of size workers. A zero retention keeps every row forever.
+func New(models data.Models, logger *jsonlog.Logger, size int, pollInterval, retention time.Duration) *Pool {
+	return &Pool{
+		jobs:          models.Jobs,
+		outbox:        models.Outbox,
+		webhooks:      models.Webhooks,
+		logger:        logger,
+		handlers:      make(map[string]Handler),
+		subscriptions: make(map[string][]string),
+		size:          size,
+		pollInterval:  pollInterval,
+		retention:     retention,
+	}
+}
+
+// Register sets the handler for a job kind. It must be called before Start.
+func (p *Pool) Register(kind string, handler Handler) {
+	p.handlers[kind] = handler
+}
+
+// Subscribe creates a job of the given kind for every outbox event of eventType.
+// The job payload is the event payload. It must be called before Start.
+func (p *Pool) Subscribe(eventType, kind string) {
+	p.subscriptions[eventType] = append(p.subscriptions[eventType], kind)
+}
+
+// Start launches the workers, the outbox dispatcher and the pruning in the
+// background.
+func (p *Pool) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	p.cancel = cancel
+
+	for i := 0; i < p.size; i++ {
+		p.wg.Add(1)
+
+		go func() {
+			defer p.wg.Done()
+			p.run(ctx)
+		}()
+	}
+
+	p.wg.Add(1)
+
+	go func() {
+		defer p.wg.Done()
+		p.dispatch(ctx)
+	}()
+
+	if p.retention > 0 {
+		p.wg.Add(1)
+
+		go func() {
+			defer p.wg.Done()
+			p.prune(ctx)
+		}()
+	}
+
+	p.logger.PrintInfo("job workers started", map[string]string{
+		"workers": fmt.Sprintf("%d", p.size),
+	})
+}
+
+// Stop stops polling for new jobs and waits for the jobs in flight to finish.
+func (p *Pool) Stop() {
+	if p.cancel == nil {
+		return
+	}
+
+	p.cancel()
+	p.wg.Wait()
+}
+
+func (p *Pool) run(ctx context.Context) {
+	ticker := time.NewTicker(p.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		// Drain all due jobs before waiting for the next tick.
+		for ctx.Err() == nil {
+			job, err := p.jobs.Claim(ctx, lockLease)
+			if err != nil {
+				if !errors.Is(err, data.ErrRecordNotFound) && ctx.Err() == nil {
+					p.logger.PrintError(err, nil)
+				}
+				break
+			}
+
+			p.process(job)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// dispatch moves outbox events to the job queue until ctx is cancelled.
+func (p *Pool) dispatch(ctx context.Context) {
+	ticker := time.NewTicker(p.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		for ctx.Err() == nil {
+			n, err := p.outbox.Dispatch(ctx, p.subscriptions, dispatchBatchSize)
+			if err != nil {
+				if ctx.Err() == nil {
+					p.logger.PrintError(err, nil)
+				}
+				break
+			}
+
+			if n < dispatchBatchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// prune deletes the rows older than the retention every pruneInterval until ctx is
+// cancelled. Running it on several instances at once is harmless.
+func (p *Pool) prune(ctx context.Context) {
+	ticker := time.NewTicker(pruneInterval)
+	defer ticker.Stop()
+
+	tables := []struct {
+		name   string
+		delete func(ctx context.Context, before time.Time, limit int) (int64, error)
+	}{
+		{"jobs", p.jobs.DeleteCompleted},
+		{"outbox", p.outbox.DeleteDispatched},
+		{"webhook_deliveries", p.webhooks.DeleteDeliveries},
+	}
+
+	for {
+		before := time.Now().Add(-p.retention)
+
+		for _, table := range tables {
+			var deleted int64
+
+			for ctx.Err() == nil {
+				n, err := table.delete(ctx, before, pruneBatchSize)
+				if err != nil {
+					if ctx.Err() == nil {
+						p.logger.PrintError(err, map[string]string{"table": table.name})
+					}
+					break
+				}
+
+				deleted += n
+
+				if n < pruneBatchSize {
+					break
+				}
+			}
+
+			if deleted > 0 {
+				p.logger.PrintInfo("pruned old rows", map[string]string{
+					"table": table.name,
+					"rows":  fmt.Sprintf("%d", deleted),
+				})
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// process runs a claimed job in a span of its own, a child of the span that caused
+// the job when its trace context was stored. The job is completed or failed even
+// when the pool is stopping, so that it isn't left to its lease to expire.
+func (p *Pool) process(job *data.Job) {
+	attrs := []attribute.KeyValue{
+		attribute.String("job.id", job.ID.String()),
+		attribute.String("job.kind", job.Kind),
+		attribute.Int("job.attempt", job.Attempts),
+	}
+
+	if job.RequestID != nil {
+		attrs = append(attrs, attribute.String("request_id", *job.RequestID))
+	}
+
+	ctx := context.Background()
+	if job.TraceParent != nil {
+		ctx = tracing.ContextWithTraceParent(ctx, *job.TraceParent)
+	}
+
+	ctx, span := tracer.Start(ctx, "job "+job.Kind,
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(attrs...),
+	)
+	defer span.End()
+
+	properties := map[string]string{
+		"job_id":   job.ID.String(),
+		"job_kind": job.Kind,
+		"attempt":  fmt.Sprintf("%d", job.Attempts),
+	}
+
+	if job.RequestID != nil {
+		properties["request_id"] = *job.RequestID
+	}
+
+	err := p.execute(ctx, job)
+	if err == nil {
+		err = p.jobs.Complete(ctx, job)
+		if err != nil {
+			p.logger.PrintError(err, properties)
+		}
+		return
+	}
+
+	p.logger.PrintError(err, properties)
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+
+	err = p.jobs.Fail(ctx, job, err, time.Now().Add(backoff(job.Attempts)))
+	if err != nil {
+		p.logger.PrintError(err, properties)
+		return
+	}
+
+	if job.Status == data.JobDead {
+		p.logger.PrintError(errors.New("job moved to dead letter storage"), properties)
+	}
+}
+
+// execute runs the job handler, turning a panic into an ordinary failure so the
+// job is retried instead of taking the worker down.
+func (p *Pool) execute(ctx context.Context, job *data.Job) (err error) {
+	handler, ok := p.handlers[job.Kind]
+	if !ok {
+		return fmt.Errorf("no handler registered for job kind %q", job.Kind)
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("job panicked: %v", r)
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(ctx, jobTimeout)
+	defer cancel()
+
+	return handler(ctx, job)
+}
+
+// backoff returns the delay before the next attempt: baseBackoff doubled for every
+// attempt made so far, capped at maxBackoff, with up to 20% jitter so failed jobs
+// don't retry in lockstep.
+func backoff(attempts int) time.Duration {
+	delay := maxBackoff
+	if attempts >= 1 && attempts < 20 {
+		delay = baseBackoff << (attempts - 1)
+	}
+
+	if delay > maxBackoff {
+		delay = maxBackoff
+	}
+
+	jitter := time.Duration(rand.Int63n(int64(delay) / 5))
+
+	return delay + jitter
+}
@@ -0,0 +1,33 @@
+package worker
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		base     time.Duration
+	}{
+		{0, maxBackoff},
+		{1, baseBackoff},
+		{2, 2 * baseBackoff},
+		{3, 4 * baseBackoff},
+		{9, 256 * baseBackoff},
+		{10, maxBackoff},
+		{19, maxBackoff},
+		{20, maxBackoff},
+		{100, maxBackoff},
+	}
+
+	for _, tt := range tests {
+		// The jitter is random, so check the bounds over a few draws.
+		for i := 0; i < 50; i++ {
+			got := backoff(tt.attempts)
+			if got < tt.base || got >= tt.base+tt.base/5 {
+				t.Fatalf("backoff(%d) = %s; want in [%s, %s)", tt.attempts, got, tt.base, tt.base+tt.base/5)
+			}
+		}
+	}
+}
@@ -1,2 +1,3 @@
+DROP TABLE IF EXISTS user_profile;
 DROP TABLE IF EXISTS users;
-DROP TABLE IF EXISTS user_profile;
\ No newline at end of file
+DROP DOMAIN IF EXISTS phone;
@@ -0,0 +1,9 @@
+ALTER TABLE workouts DROP CONSTRAINT IF EXISTS workouts_tenant_id_name_key;
+ALTER TABLE workouts ADD CONSTRAINT workouts_name_key UNIQUE (name);
+ALTER TABLE workouts DROP COLUMN IF EXISTS tenant_id;
+
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_tenant_id_email_key;
+ALTER TABLE users ADD CONSTRAINT users_email_key UNIQUE (email);
+ALTER TABLE users DROP COLUMN IF EXISTS tenant_id;
+
+DROP TABLE IF EXISTS tenants;
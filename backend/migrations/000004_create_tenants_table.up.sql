@@ -0,0 +1,22 @@
+CREATE TABLE IF NOT EXISTS tenants(
+    id UUID NOT NULL PRIMARY KEY DEFAULT gen_random_uuid(),
+    slug citext NOT NULL UNIQUE,
+    name TEXT NOT NULL,
+    is_active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Rows created before multi-tenant mode belong to the default box.
+INSERT INTO tenants (slug, name) VALUES ('default', 'Default box') ON CONFLICT (slug) DO NOTHING;
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS tenant_id UUID NULL REFERENCES tenants(id) ON DELETE CASCADE;
+UPDATE users SET tenant_id = (SELECT id FROM tenants WHERE slug = 'default') WHERE tenant_id IS NULL;
+ALTER TABLE users ALTER COLUMN tenant_id SET NOT NULL;
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_email_key;
+ALTER TABLE users ADD CONSTRAINT users_tenant_id_email_key UNIQUE (tenant_id, email);
+
+ALTER TABLE workouts ADD COLUMN IF NOT EXISTS tenant_id UUID NULL REFERENCES tenants(id) ON DELETE CASCADE;
+UPDATE workouts SET tenant_id = (SELECT id FROM tenants WHERE slug = 'default') WHERE tenant_id IS NULL;
+ALTER TABLE workouts ALTER COLUMN tenant_id SET NOT NULL;
+ALTER TABLE workouts DROP CONSTRAINT IF EXISTS workouts_name_key;
+ALTER TABLE workouts ADD CONSTRAINT workouts_tenant_id_name_key UNIQUE (tenant_id, name);
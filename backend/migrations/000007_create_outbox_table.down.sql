@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS jobs_dedup_key_idx;
+ALTER TABLE jobs DROP COLUMN IF EXISTS dedup_key;
+
+DROP TABLE IF EXISTS outbox;
@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS outbox(
+    id UUID NOT NULL PRIMARY KEY DEFAULT gen_random_uuid(),
+    tenant_id UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    event_type TEXT NOT NULL,
+    aggregate_id UUID NOT NULL,
+    payload JSONB NOT NULL DEFAULT '{}',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    dispatched_at TIMESTAMPTZ NULL
+);
+
+CREATE INDEX IF NOT EXISTS outbox_pending_idx ON outbox (created_at) WHERE dispatched_at IS NULL;
+
+-- Jobs created from outbox events carry a key derived from the event, so
+-- dispatching the same event twice doesn't create duplicate jobs.
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS dedup_key TEXT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS jobs_dedup_key_idx ON jobs (dedup_key);
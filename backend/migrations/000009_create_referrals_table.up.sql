@@ -0,0 +1,12 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS referral_code TEXT NOT NULL DEFAULT upper(substr(md5(gen_random_uuid()::text), 1, 10));
+ALTER TABLE users ADD CONSTRAINT users_tenant_id_referral_code_key UNIQUE (tenant_id, referral_code);
+
+CREATE TABLE IF NOT EXISTS referrals(
+    id UUID NOT NULL PRIMARY KEY DEFAULT gen_random_uuid(),
+    tenant_id UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    referrer_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    referred_id UUID NOT NULL UNIQUE REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS referrals_tenant_id_referrer_id_idx ON referrals (tenant_id, referrer_id);
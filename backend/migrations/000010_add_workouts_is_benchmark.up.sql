@@ -0,0 +1 @@
+ALTER TABLE workouts ADD COLUMN IF NOT EXISTS is_benchmark BOOLEAN NOT NULL DEFAULT false;
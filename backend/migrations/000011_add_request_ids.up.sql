@@ -0,0 +1,4 @@
+-- The ID of the request that caused an event is kept with the event and the jobs
+-- created from it, so that their logs can be traced back to the request.
+ALTER TABLE outbox ADD COLUMN IF NOT EXISTS request_id TEXT NULL;
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS request_id TEXT NULL;
@@ -0,0 +1,4 @@
+-- The W3C traceparent of the span that caused an event is kept with the event and
+-- the jobs created from it, so that the spans of the jobs join the request's trace.
+ALTER TABLE outbox ADD COLUMN IF NOT EXISTS traceparent TEXT NULL;
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS traceparent TEXT NULL;
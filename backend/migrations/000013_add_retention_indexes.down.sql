@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS webhook_deliveries_created_at_idx;
+DROP INDEX IF EXISTS outbox_dispatched_at_idx;
+DROP INDEX IF EXISTS jobs_completed_updated_at_idx;
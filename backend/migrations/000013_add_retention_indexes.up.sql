@@ -0,0 +1,5 @@
+-- Completed jobs, dispatched outbox events and webhook delivery logs are pruned
+-- once they are older than the retention, which these indexes keep cheap.
+CREATE INDEX IF NOT EXISTS jobs_completed_updated_at_idx ON jobs (updated_at) WHERE status = 'completed';
+CREATE INDEX IF NOT EXISTS outbox_dispatched_at_idx ON outbox (dispatched_at) WHERE dispatched_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS webhook_deliveries_created_at_idx ON webhook_deliveries (created_at);
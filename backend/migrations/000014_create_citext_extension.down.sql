@@ -0,0 +1 @@
+-- citext is kept: the users table still depends on it until 000003 is reverted.
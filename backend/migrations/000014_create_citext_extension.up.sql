@@ -0,0 +1,3 @@
+-- The users table relies on citext since 000003, which left creating it to
+-- init.sql. Applied migrations are never edited, so the extension is declared here.
+CREATE EXTENSION IF NOT EXISTS citext;
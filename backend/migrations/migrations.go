@@ -0,0 +1,7 @@
+// Package migrations embeds the SQL migrations so they ship with the API binary.
+package migrations
+
+import "embed"
+
+//go:embed *.sql
+var FS embed.FS